@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"issues"
+	"math/rand"
+	"ml"
+	"sort"
+	"strings"
+)
+
+// component returns the issue's Cr- label, truncated to depth levels
+// below Cr-. ok is false unless the issue has exactly one such
+// component.
+func component(i *issues.Issue, depth int) (c string, ok bool) {
+	for label := range i.IssueLabels {
+		parts := strings.Split(label, "-")
+		if parts[0] != "Cr" || len(parts) < 2 {
+			continue
+		}
+		if len(parts) > depth+1 {
+			parts = parts[:depth+1]
+		}
+		truncated := strings.Join(parts, "-")
+		if ok && truncated != c {
+			return "", false
+		}
+		c, ok = truncated, true
+	}
+	return
+}
+
+// componentClasses numbers the components of the issues in
+// alphabetical order.
+func componentClasses(is []*issues.Issue, depth int) []string {
+	seen := make(map[string]bool)
+	for _, i := range is {
+		if c, ok := component(i, depth); ok {
+			seen[c] = true
+		}
+	}
+	var names []string
+	for c := range seen {
+		names = append(names, c)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// componentExamples sets the class of the examples which have a
+// single component and returns them.
+func componentExamples(es []ml.Example, names []string, depth int) []ml.MultiClassExample {
+	classes := make(map[string]int)
+	for class, name := range names {
+		classes[name] = class
+	}
+	var mces []ml.MultiClassExample
+	for _, e := range es {
+		example := e.(*IssueExample)
+		if c, ok := component(example.Issue, depth); ok {
+			example.class = classes[c]
+			mces = append(mces, example)
+		}
+	}
+	return mces
+}
+
+// topKAccuracy returns the fraction of examples whose class is one
+// of the k highest ranked.
+func topKAccuracy(booster *ml.SAMME, es []ml.MultiClassExample, k int) float64 {
+	hits := 0
+	for _, e := range es {
+		for _, class := range booster.Rank(e)[:k] {
+			if class == e.Class() {
+				hits++
+				break
+			}
+		}
+	}
+	return float64(hits) / float64(len(es))
+}
+
+// trainComponents boosts multi-class decision trees which rank the
+// Cr- components an issue may belong to.
+func trainComponents(r *rand.Rand, is []*issues.Issue, dev []ml.Example, test []ml.Example) {
+	names := componentClasses(is, *componentDepth)
+	devComponents := componentExamples(dev, names, *componentDepth)
+	testComponents := componentExamples(test, names, *componentDepth)
+	fmt.Printf("%d components, %d dev and %d test issues with one component\n", len(names), len(devComponents), len(testComponents))
+
+	var devExamples []ml.Example
+	for _, e := range devComponents {
+		devExamples = append(devExamples, e)
+	}
+	// Any Cr- label gives the component away.
+	features := withoutTarget(extractFeatures(devExamples).Features, "Cr")
+	fmt.Printf("%d features\n", len(features))
+
+	treeBuilder := ml.NewDecisionTreeBuilder(features, flagLearnerConfig().treeDepth(5))
+	treeBuilder.Parallelism = *parallelism
+	booster := ml.NewSAMME(devComponents, len(names), treeBuilder, r)
+	booster.Parallelism = *parallelism
+	k := 3
+	if k > len(names) {
+		k = len(names)
+	}
+
+	for i := 0; ; i++ {
+		booster.Round(*sampleSize)
+		fmt.Printf("%d: dev=%f test=%f test top %d=%f a=%f\n", i, booster.Evaluate(devComponents), booster.Evaluate(testComponents), k, topKAccuracy(booster, testComponents, k), booster.A[i])
+		if len(testComponents) == 0 {
+			continue
+		}
+		e := testComponents[i%len(testComponents)].(*IssueExample)
+		var candidates []string
+		for _, class := range booster.Rank(e)[:k] {
+			candidates = append(candidates, names[class])
+		}
+		fmt.Printf("  issue %d (%s): %s\n", e.Id, names[e.Class()], strings.Join(candidates, ", "))
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"ml"
+	"strconv"
+)
+
+// issueBlockSize is how many consecutive issue IDs are grouped
+// together when cross-validating with id-block folds. Issue IDs
+// increase over time, so this holds out a period of time.
+const issueBlockSize = 2000
+
+func makeFolds(r *rand.Rand, es []ml.Example) ml.Folds {
+	if *folds < 2 && *cvMethod != "id-block" {
+		log.Fatalf("Can not cross-validate with %d folds; use -folds 2 or more", *folds)
+	}
+	switch *cvMethod {
+	case "kfold":
+		return ml.KFold(len(es), *folds, r)
+	case "stratified":
+		return ml.StratifiedKFold(es, *folds, r)
+	case "id-block":
+		return ml.LeaveOneGroupOut(es, func(e ml.Example) string {
+			return strconv.Itoa(issueExampleOf(e).Id / issueBlockSize)
+		})
+	default:
+		log.Fatalf("Unknown cross-validation method \"%s\"", *cvMethod)
+		return nil
+	}
+}
+
+// learnerConfig holds the knobs of the learners crossvalidate and
+// search modes train. Its fields are named as in search configs.
+type learnerConfig struct {
+	learner string
+	// depth is the maximum depth of decision trees; 0 uses 3 for
+	// boosting and 10 for random forests.
+	depth         int
+	sampleSize    int
+	minSupport    float64
+	positivePrior float64
+	rounds        int
+	trees         int
+	learningRate  float64
+	subsample     float64
+	l1            float64
+	l2            float64
+	alpha         float64
+	parallelism   int
+}
+
+// flagLearnerConfig returns the learner config the flags set.
+func flagLearnerConfig() *learnerConfig {
+	return &learnerConfig{*learnerName, *depth, *sampleSize, *minSupport, *positivePrior, *rounds, *trees, *learningRate, *subsample, *l1, *l2, *alpha, *parallelism}
+}
+
+// set sets the knob with the given search param name.
+func (c *learnerConfig) set(name string, value float64) error {
+	switch name {
+	case "depth":
+		c.depth = int(value)
+	case "sample-size":
+		c.sampleSize = int(value)
+	case "min-support":
+		c.minSupport = value
+	case "positive-prior":
+		c.positivePrior = value
+	case "rounds":
+		c.rounds = int(value)
+	case "trees":
+		c.trees = int(value)
+	case "learning-rate":
+		c.learningRate = value
+	case "subsample":
+		c.subsample = value
+	case "l1":
+		c.l1 = value
+	case "l2":
+		c.l2 = value
+	case "alpha":
+		c.alpha = value
+	default:
+		return fmt.Errorf("Unknown parameter \"%s\"", name)
+	}
+	return nil
+}
+
+// treeDepth returns the maximum depth of decision trees, or def if
+// the config does not set one.
+func (c *learnerConfig) treeDepth(def int) int {
+	if c.depth == 0 {
+		return def
+	}
+	return c.depth
+}
+
+// newLearnerFactory returns a factory for the learner c configures.
+// Learners of sparse vectors vectorize es, all of the examples being
+// cross-validated, so es must not be shared with other factories.
+func newLearnerFactory(r *rand.Rand, es []ml.Example, c *learnerConfig) ml.LearnerFactory {
+	return func(train []ml.Example) ml.Learner {
+		switch c.learner {
+		case "adaboost":
+			treeBuilder := ml.NewDecisionTreeBuilder(withoutTarget(extractFeaturesWithMinSupport(train, c.minSupport).Features, blinkLabel), c.treeDepth(3))
+			treeBuilder.Parallelism = c.parallelism
+			treeBuilder.Numeric, treeBuilder.Categorical = metadataFeatures(blinkLabel)
+			return &ml.AdaBoostLearner{Weak: treeBuilder, Rounds: c.rounds, SampleSize: c.sampleSize, Rand: r, Parallelism: c.parallelism, Reweight: *reweight, Prior: c.positivePrior, Costs: classCosts()}
+		case "forest":
+			// Bagging averages away the variance of deep trees.
+			treeBuilder := ml.NewDecisionTreeBuilder(withoutTarget(extractFeaturesWithMinSupport(train, c.minSupport).Features, blinkLabel), c.treeDepth(10))
+			treeBuilder.Parallelism = c.parallelism
+			treeBuilder.Numeric, treeBuilder.Categorical = metadataFeatures(blinkLabel)
+			return ml.NewRandomForestLearner(treeBuilder, c.trees, r)
+		case "gbdt":
+			features := withoutTarget(extractFeaturesWithMinSupport(train, c.minSupport).Features, blinkLabel)
+			treeBuilder := ml.NewRegressionTreeBuilder(features, c.treeDepth(3))
+			treeBuilder.Parallelism = c.parallelism
+			return &ml.GradientBoostingLearner{Features: features, Weak: treeBuilder, Rounds: c.rounds, LearningRate: c.learningRate, Subsample: c.subsample, Rand: r}
+		case "multinomial-nb", "bernoulli-nb", "logistic":
+			// The vectors of the held out examples are made from the
+			// training examples' vocabulary and document frequencies
+			// too, as they would be for new issues.
+			dimension, features := vectorizeFold(train, es)
+			switch c.learner {
+			case "multinomial-nb", "bernoulli-nb":
+				if hasher != nil && hasher.Signed {
+					log.Fatal("Naive Bayes can not learn from negative counts; use -signed-hash=false")
+				}
+				model := ml.MultinomialNaiveBayes
+				if c.learner == "bernoulli-nb" {
+					model = ml.BernoulliNaiveBayes
+				}
+				learner := ml.NewNaiveBayesLearner(model, dimension)
+				learner.Alpha = c.alpha
+				return learner
+			}
+			learner := ml.NewLogisticRegressionLearner(dimension, features, r)
+			learner.L1, learner.L2 = c.l1, c.l2
+			return learner
+		default:
+			log.Fatalf("Unknown learner \"%s\"", c.learner)
+			return nil
+		}
+	}
+}
+
+// crossValidateBlink cross-validates a Cr-Blink model, extracting
+// features from each fold's training examples.
+func crossValidateBlink(r *rand.Rand, es []ml.Example) {
+	cv := ml.CrossValidate(newLearnerFactory(r, es, flagLearnerConfig()), es, makeFolds(r, es))
+	for i, ev := range cv.Evaluations {
+		fmt.Printf("fold %d: %v\n", i, ev)
+	}
+	fmt.Printf("%v\n", cv)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"ml"
+	"os"
+	"sort"
+)
+
+type issueExplanation struct {
+	Id          int             `json:"id"`
+	Title       string          `json:"title"`
+	Explanation *ml.Explanation `json:"explanation"`
+}
+
+// explainTopIssues explains why the model scores the n highest
+// scoring examples as it does, highest first. It prints the
+// explanations, or writes them to the -explanations file as one JSON
+// object per line.
+func explainTopIssues(a *ml.AdaBoost, es []ml.Example, n int) {
+	if n <= 0 {
+		return
+	}
+	es = append([]ml.Example(nil), es...)
+	scores := make(map[ml.Example]float64)
+	for _, e := range es {
+		scores[e] = a.Predict(e)
+	}
+	sort.SliceStable(es, func(i, j int) bool {
+		return scores[es[i]] > scores[es[j]]
+	})
+	if n < len(es) {
+		es = es[:n]
+	}
+
+	var out *json.Encoder
+	if *explanationsPath != "" {
+		f, err := os.Create(*explanationsPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		out = json.NewEncoder(f)
+	}
+	for _, e := range es {
+		i := issueExampleOf(e)
+		ex := a.Explain(e)
+		if out != nil {
+			if err := out.Encode(&issueExplanation{i.Id, i.Title, ex}); err != nil {
+				log.Fatal(err)
+			}
+			continue
+		}
+		fmt.Printf("issue %d (%s, Cr-Blink=%t): %v\n", i.Id, i.Title, bool(e.Label()), ex)
+	}
+}
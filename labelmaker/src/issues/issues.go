@@ -23,6 +23,7 @@ import (
 	"html"
 	"regexp"
 	"strconv"
+	"time"
 )
 
 // State indicates whether an issue is open or closed.
@@ -62,6 +63,16 @@ type Issue struct {
 	State       State
 	Status      Status
 	IssueLabels Labels
+	Stars       int
+	CC          []string
+	Published   time.Time
+	Updated     time.Time
+	// Closed is the zero time if the issue has no closed date.
+	Closed time.Time
+	// Author is the email address of the issue's reporter. The feed
+	// obscures most addresses, eg SunFi...@gmail.com, but not their
+	// domains.
+	Author string
 }
 
 func parseIssueDecodedJson(entry map[string]interface{}) (*Issue, error) {
@@ -73,6 +84,12 @@ func parseIssueDecodedJson(entry map[string]interface{}) (*Issue, error) {
 		p.state(),
 		p.status(),
 		p.labels(),
+		p.stars(),
+		p.cc(),
+		p.time("published"),
+		p.time("updated"),
+		p.time("issues$closedDate"),
+		p.author(),
 	}
 	if p.err != nil {
 		return nil, p.err
@@ -147,6 +164,51 @@ func (p *issueParser) labels() Labels {
 	return ls
 }
 
+func (p *issueParser) stars() int {
+	s := p.entry["issues$stars"]
+	if s == nil {
+		return 0
+	}
+	return int(s.(map[string]interface{})["$t"].(float64))
+}
+
+// author returns the name of the first author, or "" if there is
+// none.
+func (p *issueParser) author() string {
+	authors, ok := p.entry["author"].([]interface{})
+	if !ok || len(authors) == 0 {
+		return ""
+	}
+	return authors[0].(map[string]interface{})["name"].(map[string]interface{})["$t"].(string)
+}
+
+func (p *issueParser) cc() []string {
+	ccJson := p.entry["issues$cc"]
+	if ccJson == nil {
+		return nil
+	}
+	var cc []string
+	for _, value := range ccJson.([]interface{}) {
+		cc = append(cc, value.(map[string]interface{})["issues$username"].(map[string]interface{})["$t"].(string))
+	}
+	return cc
+}
+
+// time parses the timestamp in the named field. Missing fields are the
+// zero time.
+func (p *issueParser) time(field string) time.Time {
+	t := p.entry[field]
+	if t == nil {
+		return time.Time{}
+	}
+	s := t.(map[string]interface{})["$t"].(string)
+	parsed, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		p.err = fmt.Errorf("Could not parse %s \"%s\": %v", field, s, err)
+	}
+	return parsed
+}
+
 func ParseIssuesJson(content []byte) ([]*Issue, error) {
 	var doc interface{}
 	err := json.Unmarshal(content, &doc)
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"issues"
+	"log"
+	"math/rand"
+	"ml"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// labelFamily truncates a label to depth dash-separated parts, eg
+// Cr-Blink-Layout at depth 2 is Cr-Blink. Depth 0 leaves the label
+// alone.
+func labelFamily(label string, depth int) string {
+	if depth == 0 {
+		return label
+	}
+	parts := strings.SplitN(label, "-", depth+1)
+	if len(parts) > depth {
+		parts = parts[:depth]
+	}
+	return strings.Join(parts, "-")
+}
+
+func hasLabelFamily(i *issues.Issue, family string, depth int) bool {
+	for label := range i.IssueLabels {
+		if labelFamily(label, depth) == family {
+			return true
+		}
+	}
+	return false
+}
+
+// labelFamilies returns the label families which at least minIssues
+// of the examples have, in alphabetical order.
+func labelFamilies(es []ml.Example, depth int, minIssues int) []string {
+	counts := make(map[string]int)
+	for _, e := range es {
+		families := make(map[string]bool)
+		for label := range issueExampleOf(e).IssueLabels {
+			families[labelFamily(label, depth)] = true
+		}
+		for family := range families {
+			counts[family]++
+		}
+	}
+	var families []string
+	for family, n := range counts {
+		if n >= minIssues {
+			families = append(families, family)
+		}
+	}
+	sort.Strings(families)
+	return families
+}
+
+// labelModel is a binary model which predicts one label family.
+type labelModel struct {
+	family  string
+	booster *ml.AdaBoost
+	ev      *ml.Evaluation
+}
+
+// trainLabelModel boosts trees which predict family, from the
+// features of dev which do not give family away. Each model has its
+// own random source so that the results do not depend on the order in
+// which the models are trained.
+func trainLabelModel(seed int64, family string, dev *ml.FeatureMatrix, test []ml.Example) *labelModel {
+	treeBuilder := ml.NewDecisionTreeBuilder(withoutTarget(dev.Features, family), flagLearnerConfig().treeDepth(3))
+	treeBuilder.Numeric, treeBuilder.Categorical = metadataFeatures(family)
+
+	r := rand.New(rand.NewSource(seed))
+	label := func(e ml.Example) ml.Label {
+		return ml.Label(hasLabelFamily(issueExampleOf(e).Issue, family, *labelDepth))
+	}
+	dev = dev.Relabel(ml.Relabel(dev.Examples, label))
+	test = ml.Relabel(test, label)
+
+	booster := ml.NewAdaBoostOnMatrix(dev, treeBuilder, r)
+	booster.SetInitialDistribution(ml.PriorDistribution(dev.Examples, *positivePrior))
+	booster.Costs = classCosts()
+	for i := 0; i < *rounds; i++ {
+		booster.Round(*sampleSize)
+	}
+	return &labelModel{family, booster, ml.Evaluate(booster, test)}
+}
+
+// labelSuggestion is a label predicted for an issue, and its score.
+type labelSuggestion struct {
+	Label string  `json:"label"`
+	Score float64 `json:"score"`
+}
+
+type issueSuggestions struct {
+	Id          int               `json:"id"`
+	Suggestions []labelSuggestion `json:"suggestions"`
+}
+
+// suggestLabels returns the label families the models predict for e,
+// highest scoring first.
+func suggestLabels(models []*labelModel, e ml.Example) []labelSuggestion {
+	var suggestions []labelSuggestion
+	for _, m := range models {
+		if score := m.booster.Predict(e); score > 0.0 {
+			suggestions = append(suggestions, labelSuggestion{m.family, score})
+		}
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Score > suggestions[j].Score
+	})
+	return suggestions
+}
+
+// trainLabels trains one binary model per label family, in parallel,
+// and suggests labels for the test issues. All the models share the
+// same examples and feature matrix. The models are trained in
+// parallel, so each model is trained with one goroutine.
+func trainLabels(dev []ml.Example, test []ml.Example) {
+	families := labelFamilies(dev, *labelDepth, *minLabelIssues)
+	fmt.Printf("%d label families with at least %d dev issues\n", len(families), *minLabelIssues)
+
+	m := extractFeatures(dev)
+	fmt.Printf("%d features\n", len(m.Features))
+
+	models := make([]*labelModel, len(families))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < *parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				models[i] = trainLabelModel(int64(i), families[i], m, test)
+			}
+		}()
+	}
+	for i := range families {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	for _, m := range models {
+		fmt.Printf("%s: f1=%f roc_auc=%f pr_auc=%f\n", m.family, m.ev.F1, m.ev.ROCAUC, m.ev.PRAUC)
+	}
+
+	var out *json.Encoder
+	if *suggestionsPath != "" {
+		f, err := os.Create(*suggestionsPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		out = json.NewEncoder(f)
+	}
+	for _, e := range test {
+		i := issueExampleOf(e)
+		suggestions := suggestLabels(models, e)
+		if out != nil {
+			if err := out.Encode(&issueSuggestions{i.Id, suggestions}); err != nil {
+				log.Fatal(err)
+			}
+			continue
+		}
+		var formatted []string
+		for _, s := range suggestions {
+			formatted = append(formatted, fmt.Sprintf("%s (%.2f)", s.Label, s.Score))
+		}
+		fmt.Printf("issue %d: %s\n", i.Id, strings.Join(formatted, ", "))
+	}
+}
@@ -3,18 +3,21 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"issues"
 	"log"
-	"math"
 	"math/rand"
 	"ml"
 	"os"
 	"path/filepath"
+	"runtime"
 	"runtime/pprof"
+	"sort"
 	"strings"
+	"terms"
 )
 
 func loadIssues(glob string) ([]*issues.Issue, error) {
@@ -45,26 +48,83 @@ type IssueExample struct {
 	*issues.Issue
 	titleWords   map[string]bool
 	contentWords map[string]bool
+	// The issue's component, when training a multi-class model.
+	class int
+	// The issue's word counts; see vectorize.
+	vector ml.SparseVector
+}
+
+// featurizer splits titles and content into words, as -tokenizer
+// says; nil splits them at single spaces.
+var featurizer *terms.Featurizer
+
+// newFeaturizer returns the featurizer the text flags describe.
+func newFeaturizer() *terms.Featurizer {
+	switch *tokenizer {
+	case "space":
+		return nil
+	case "words":
+		f := terms.NewFeaturizer()
+		if !*stopWords {
+			f.StopWords = nil
+		}
+		f.Stem = *stem
+		f.WordNGrams = *wordNGrams
+		f.CharNGrams = *charNGrams
+		return f
+	default:
+		log.Fatalf("Unknown tokenizer \"%s\"", *tokenizer)
+		return nil
+	}
+}
+
+// issueWords returns the words of an issue's title or content, with
+// repeats, which title and content features look for.
+func issueWords(s string) []string {
+	if featurizer == nil {
+		return strings.Split(s, " ")
+	}
+	return featurizer.Terms(s)
 }
 
 func wordsHash(s string) map[string]bool {
 	m := make(map[string]bool)
-	words := strings.Split(s, " ")
-	for _, word := range words {
+	for _, word := range issueWords(s) {
 		m[word] = true
 	}
 	return m
 }
 
 func NewIssueExample(i *issues.Issue) *IssueExample {
-	return &IssueExample{i, wordsHash(i.Title), wordsHash(i.Content)}
+	e := &IssueExample{i, wordsHash(i.Title), wordsHash(i.Content), -1, ml.SparseVector{}}
+	if hasher != nil {
+		e.vector = hashedVector(e)
+	}
+	return e
+}
+
+// blinkLabel is the label IssueExamples are labelled with, unless
+// relabeled.
+const blinkLabel = "Cr-Blink"
+
+// issueExampleOf returns the IssueExample that e was made from.
+func issueExampleOf(e ml.Example) *IssueExample {
+	return ml.Unwrap(e).(*IssueExample)
 }
 
 func (is *IssueExample) Label() ml.Label {
-	_, ok := is.IssueLabels["Cr-Blink"]
+	_, ok := is.IssueLabels[blinkLabel]
 	return ml.Label(ok)
 }
 
+func (is *IssueExample) Class() int {
+	return is.class
+}
+
+func (is *IssueExample) Vector() ml.SparseVector {
+	return is.vector
+}
+
 type titleFeature struct {
 	word string
 }
@@ -74,7 +134,7 @@ func (t *titleFeature) String() string {
 }
 
 func (t *titleFeature) Predict(e ml.Example) float64 {
-	if _, ok := e.(*IssueExample).titleWords[t.word]; ok {
+	if _, ok := issueExampleOf(e).titleWords[t.word]; ok {
 		return 1.0
 	} else {
 		return -1.0
@@ -86,48 +146,94 @@ type contentFeature struct {
 }
 
 func (f *contentFeature) String() string {
-	return f.word
+	return fmt.Sprintf("content*%s", f.word)
 }
 
 func (f *contentFeature) Predict(e ml.Example) float64 {
-	if _, ok := e.(*IssueExample).contentWords[f.word]; ok {
+	if _, ok := issueExampleOf(e).contentWords[f.word]; ok {
 		return 1.0
 	} else {
 		return -1.0
 	}
 }
 
-func extractFeatures(examples []ml.Example) (features []ml.Feature) {
-	features = nil
+func init() {
+	ml.RegisterFeature("title", &titleFeature{}, func(f ml.Feature) (interface{}, error) {
+		return f.(*titleFeature).word, nil
+	}, func(data json.RawMessage) (ml.Feature, error) {
+		var word string
+		err := json.Unmarshal(data, &word)
+		return &titleFeature{word}, err
+	})
+	ml.RegisterFeature("content", &contentFeature{}, func(f ml.Feature) (interface{}, error) {
+		return f.(*contentFeature).word, nil
+	}, func(data json.RawMessage) (ml.Feature, error) {
+		var word string
+		err := json.Unmarshal(data, &word)
+		return &contentFeature{word}, err
+	})
+}
+
+// extractFeatures finds the title and content words which occur in
+// at least the -min-support fraction of the examples.
+func extractFeatures(examples []ml.Example) *ml.FeatureMatrix {
+	return extractFeaturesWithMinSupport(examples, *minSupport)
+}
+
+// extractFeaturesWithMinSupport finds the title and content words,
+// and the labels, which occur in enough of the examples to be useful
+// features, and returns a matrix of them. The features are sorted so
+// that training does not depend on the order of map iteration. The
+// label features include the labels models predict; see
+// withoutTarget.
+func extractFeaturesWithMinSupport(examples []ml.Example, minSupport float64) *ml.FeatureMatrix {
+	minExamples := int(minSupport * float64(len(examples)))
+	var features []ml.Feature
+	var featurePostings [][]int
+	if hasher != nil {
+		features, featurePostings = hashedFeaturePostings(examples, minExamples)
+	} else {
+		features, featurePostings = wordFeaturePostings(examples, minExamples)
+	}
+	labels, labelPostings := labelFeaturePostings(examples, minExamples)
+	return ml.NewFeatureMatrixFromPostings(append(features, labels...), examples, append(featurePostings, labelPostings...))
+}
 
+// wordFeaturePostings returns a feature for each title and content
+// word at least minExamples of the examples have, in alphabetical
+// order, and the examples which have it.
+func wordFeaturePostings(examples []ml.Example, minExamples int) ([]ml.Feature, [][]int) {
 	featureDeDup := make(map[string]ml.Feature)
-	for _, example := range examples {
-		for word := range example.(*IssueExample).titleWords {
+	postings := make(map[string][]int)
+	for i, example := range examples {
+		for word := range issueExampleOf(example).titleWords {
 			feature := &titleFeature{word}
 			featureDeDup[feature.String()] = feature
+			postings[feature.String()] = append(postings[feature.String()], i)
 		}
-		for word := range example.(*IssueExample).contentWords {
+		for word := range issueExampleOf(example).contentWords {
 			feature := &contentFeature{word}
 			featureDeDup[feature.String()] = feature
+			postings[feature.String()] = append(postings[feature.String()], i)
 		}
 	}
 
-	minExamples := int(0.001 * float64(len(examples)))
 	maxExamples := len(examples)
-	for _, feature := range featureDeDup {
-		count := 0
-		for _, example := range examples {
-			if !math.Signbit(feature.Predict(example)) {
-				count++
-			}
-		}
-
-		if minExamples <= count && count <= maxExamples {
-			features = append(features, feature)
+	var names []string
+	for name, posting := range postings {
+		if count := len(posting); minExamples <= count && count <= maxExamples {
+			names = append(names, name)
 		}
 	}
+	sort.Strings(names)
 
-	return
+	features := make([]ml.Feature, len(names))
+	featurePostings := make([][]int, len(names))
+	for i, name := range names {
+		features[i] = featureDeDup[name]
+		featurePostings[i] = postings[name]
+	}
+	return features, featurePostings
 }
 
 func debugCountLabelOccurrence(name string, set []ml.Example) {
@@ -154,26 +260,183 @@ func debugDumpExampleWeights(a *ml.AdaBoost) {
 	ml.DebugCharacterizeWeights("-ve", negatives)
 }
 
-// Factor is a scale factor to unbalance the classes again.
-// FIXME: It would be better to tweak the distribution to rebalance the classes.
-func trimToBalanceClasses(r *rand.Rand, es []ml.Example, factor int) []ml.Example {
-	var xs []ml.Example
-	var ys []ml.Example
-	for i, e := range es {
-		if e.Label() {
-			xs = append(xs, e)
-		} else if r.Intn(i + 1) < len(xs) * factor {
-			ys = append(ys, e)
+// classCosts returns the costs of misclassification the flags set, or
+// nil if they are symmetric.
+func classCosts() *ml.Costs {
+	if *falsePositiveCost == *falseNegativeCost {
+		return nil
+	}
+	return &ml.Costs{FalsePositive: *falsePositiveCost, FalseNegative: *falseNegativeCost}
+}
+
+// saveModel writes the model to path. It writes to a temporary file
+// first so that interrupting training does not leave a truncated
+// model behind.
+func saveModel(path string, c ml.Classifier) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := ml.SaveModelWithSettings(f, c, featureSettings()); err != nil {
+		f.Close()
+		return fmt.Errorf("Saving %s: %v", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// featureFlags are the flags which decide how the features of issues
+// are made. Models are saved with them, because a model's features
+// only match issues whose features are made the same way.
+var featureFlags = []string{"tokenizer", "stop-words", "stem", "word-ngrams", "char-ngrams", "vectors", "hash-bits", "signed-hash"}
+
+// featureSettings returns the values of featureFlags.
+func featureSettings() map[string]string {
+	settings := make(map[string]string)
+	for _, name := range featureFlags {
+		settings[name] = flag.Lookup(name).Value.String()
+	}
+	return settings
+}
+
+// restoreFeatureSettings sets featureFlags to the values the model at
+// path was saved with. Flags set on the command line must agree.
+func restoreFeatureSettings(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	settings, err := ml.LoadModelSettings(f)
+	if err != nil {
+		return fmt.Errorf("Loading %s: %v", path, err)
+	}
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	for _, name := range featureFlags {
+		value, ok := settings[name]
+		if !ok {
+			return fmt.Errorf("%s does not record -%s", path, name)
 		}
+		if current := flag.Lookup(name).Value.String(); set[name] && current != value {
+			return fmt.Errorf("%s was trained with -%s=%s, not %s", path, name, value, current)
+		}
+		if err := flag.Set(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadModel(path string) (*ml.AdaBoost, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	a, err := ml.LoadAdaBoost(f)
+	if err != nil {
+		return nil, fmt.Errorf("Loading %s: %v", path, err)
 	}
-	return append(xs, ys...)
+	return a, nil
 }
 
+// writeEvaluation writes ev to path as JSON, or as CSV if path ends
+// in .csv.
+func writeEvaluation(path string, ev *ml.Evaluation) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if filepath.Ext(path) == ".csv" {
+		err = ev.WriteCSV(f)
+	} else {
+		err = ev.WriteJSON(f)
+	}
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("Writing %s: %v", path, err)
+	}
+	return f.Close()
+}
+
+var mode = flag.String("mode", "blink", "what to train (blink: whether issues are Cr-Blink, components: which Cr- component issues belong to, labels: one model per label, crossvalidate: cross-validate the blink model; see -learner, report: the most important features of the model from -load, search: search the knobs of -learner by cross-validation)")
+var componentDepth = flag.Int("component-depth", 1, "how many levels of Cr- labels to distinguish in components mode, eg 1 for Cr-Blink, 2 for Cr-Blink-Layout")
+var labelDepth = flag.Int("label-depth", 0, "in labels mode, train one model per label prefix of this many dash-separated parts, eg 2 for Cr-Blink; 0 trains one model per label")
+var minLabelIssues = flag.Int("min-label-issues", 20, "in labels mode, skip labels which fewer dev issues have")
+var rounds = flag.Int("rounds", 50, "in labels and crossvalidate modes, how many rounds of boosting to do per model")
+var depth = flag.Int("depth", 0, "the maximum depth of decision trees; 0 uses 3 for boosting, 5 in components mode and 10 for random forests")
+var sampleSize = flag.Int("sample-size", 1000, "how many examples to sample for each round of boosting")
+var minSupport = flag.Float64("min-support", 0.001, "the smallest fraction of the dev issues a word must occur in to be a feature")
+var alpha = flag.Float64("alpha", 1.0, "the smoothing pseudo-count of Naive Bayes")
+var learnerName = flag.String("learner", "adaboost", "in crossvalidate mode, what to train (adaboost, forest for a random forest, gbdt for gradient boosted trees, multinomial-nb or bernoulli-nb for Naive Bayes, or logistic for logistic regression)")
+var learningRate = flag.Float64("learning-rate", 0.1, "how much to shrink each tree in gradient boosting")
+var subsample = flag.Float64("subsample", 0.5, "the fraction of the examples to fit each tree to in gradient boosting")
+var trees = flag.Int("trees", 100, "how many trees to build in a random forest")
+var l1 = flag.Float64("l1", 0.0, "L1 regularization of logistic regression")
+var l2 = flag.Float64("l2", 1e-6, "L2 regularization of logistic regression")
+var folds = flag.Int("folds", 5, "in crossvalidate mode, how many folds to use")
+var cvMethod = flag.String("cv", "stratified", "in crossvalidate mode, how to make folds (kfold, stratified, or id-block to hold out blocks of consecutive issues)")
+var maxRounds = flag.Int("max-rounds", 0, "in blink mode, stop boosting after this many rounds; 0 does not limit the rounds")
+var patience = flag.Int("patience", 0, "in blink mode, stop boosting when the validation metric has not improved for this many rounds; 0 waits forever")
+var budget = flag.Duration("budget", 0, "in blink mode, stop boosting after this long, eg 30m; 0 does not limit the time")
+var stopMetric = flag.String("stop-metric", "log_loss", "in blink mode, the validation metric which chooses the best round")
+var gainRatio = flag.Bool("gain-ratio", false, "in blink mode, choose decision tree splits by gain ratio instead of information gain")
+var reweight = flag.Bool("reweight", false, "in blink and crossvalidate modes, build each tree from every example with its AdaBoost weight instead of from a weighted sample")
+var positivePrior = flag.Float64("positive-prior", 0.25, "in blink, labels and crossvalidate modes, the initial share of AdaBoost's weight given to positive examples; 0.5 balances the classes")
+var falsePositiveCost = flag.Float64("false-positive-cost", 1.0, "in blink, labels and crossvalidate modes, the cost of wrongly predicting a label, relative to -false-negative-cost")
+var falseNegativeCost = flag.Float64("false-negative-cost", 1.0, "in blink, labels and crossvalidate modes, the cost of missing a label, relative to -false-positive-cost")
+var prune = flag.String("prune", "none", "in blink mode, how to prune decision trees (none, reduced-error, or pessimistic)")
+var calibration = flag.String("calibrate", "none", "in blink mode, how to turn the model's scores into probabilities with the validation issues (none, platt, or isotonic); the saved model is calibrated")
+var reliabilityPath = flag.String("reliability", "", "in blink mode, write the reliability diagram of the calibrated model on the test set to this file as JSON")
+var explain = flag.Int("explain", 0, "in blink mode, explain the scores of this many of the highest scoring test issues")
+var explanationsPath = flag.String("explanations", "", "in blink mode, write the explanations to this file as JSON instead of printing them")
+var top = flag.Int("top", 20, "in report mode, how many features to list")
+var reportMetric = flag.String("report-metric", "roc_auc", "in report mode, the validation metric which permutation importance is the loss of")
+var treeTextPath = flag.String("tree-text", "", "in report mode, write the model's trees to this file as indented text, with the dev issues which reach each node")
+var dotPath = flag.String("dot", "", "in report mode, write the model's trees to this file as a Graphviz graph, with the dev issues which reach each node")
+var searchMethod = flag.String("search", "grid", "in search mode, how to choose the configs to try (grid, or random)")
+var searchTrials = flag.Int("search-trials", 20, "in search mode, how many configs random search tries")
+var searchMetric = flag.String("search-metric", "roc_auc", "in search mode, the mean cross-validated metric which chooses the best config")
+var searchResultsPath = flag.String("search-results", "search.csv", "in search mode, write a row per config to this file as CSV")
+var searchBestPath = flag.String("search-best", "search-best.json", "in search mode, write the best config and its evaluation to this file as JSON")
+var parallelism = flag.Int("parallelism", runtime.NumCPU(), "how many goroutines to train with; results do not depend on it")
+var suggestionsPath = flag.String("suggestions", "", "in labels mode, write the suggested labels for each test issue to this file as JSON")
 var cpuprofile = flag.String("cpuprofile", "", "write CPU profile to file")
+var tokenizer = flag.String("tokenizer", "words", "how to split titles and content into the words of features (words: Unicode words and identifiers, lowercased; space: at single spaces, as before this flag); -load restores the text flags a model was trained with")
+var stopWords = flag.Bool("stop-words", true, "with -tokenizer=words, leave out common English words")
+var stem = flag.Bool("stem", true, "with -tokenizer=words, reduce words to their Porter stems")
+var wordNGrams = flag.Int("word-ngrams", 1, "with -tokenizer=words, also make features of runs of up to this many words")
+var charNGrams = flag.Int("char-ngrams", 0, "with -tokenizer=words, also make features of the runs of this many characters in each word; 0 makes none")
+var vectors = flag.String("vectors", "vocabulary", "how to number words as features (vocabulary: one feature per word of the training issues; hash: hash words into -hash-bits features, so that the number of words does not drive memory); -load restores the -vectors, -hash-bits and -signed-hash a model was trained with")
+var hashBits = flag.Int("hash-bits", 18, "with -vectors=hash, hash words into 2^hash-bits features, from 1 to 62")
+var signedHash = flag.Bool("signed-hash", true, "with -vectors=hash, give about half of the words negative counts, so that words which share a feature cancel out rather than add up; Naive Bayes needs -signed-hash=false")
+var weighting = flag.String("weighting", "count", "how to weight the word vectors of Naive Bayes and logistic regression (count, tfidf, or sublinear-tfidf to dampen repeated words)")
 var dataset = flag.String("dataset", "small", "which dataset to use (small, large)")
+var loadPath = flag.String("load", "", "continue boosting the model saved in this file")
+var savePath = flag.String("save", "", "save the model to this file after every round")
+var evaluationPath = flag.String("evaluation", "", "write the test set evaluation to this file (JSON, or CSV if it ends in .csv) after every round")
 
 func main() {
 	flag.Parse()
+	if *positivePrior <= 0.0 || *positivePrior >= 1.0 {
+		log.Fatalf("Can not give positive examples %g of the weight; use -positive-prior between 0 and 1", *positivePrior)
+	}
+	if *parallelism < 1 {
+		log.Fatalf("Can not train with %d goroutines; use -parallelism 1 or more", *parallelism)
+	}
+	if *loadPath != "" {
+		if err := restoreFeatureSettings(*loadPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+	featurizer = newFeaturizer()
+	hasher = newHasher()
 
 	if *cpuprofile != "" {
 		f, err := os.Create(*cpuprofile)
@@ -197,18 +460,12 @@ func main() {
 	var test []ml.Example = nil
 	for _, i := range is {
 		switch r.Intn(9) {
-		case 0:
-		case 1:
-		case 2:
+		case 0, 1, 2:
 			test = append(test, NewIssueExample(i))
-			break
-		case 3:
-		case 4:
+		case 3, 4:
 			validation = append(validation, NewIssueExample(i))
-			break
 		default:
 			dev = append(dev, NewIssueExample(i))
-			break
 		}
 	}
 
@@ -217,25 +474,168 @@ func main() {
 	debugCountLabelOccurrence("dev", dev)
 	debugCountLabelOccurrence("test", test)
 
+	switch *mode {
+	case "blink":
+		trainBlink(r, dev, validation, test)
+	case "components":
+		trainComponents(r, is, dev, test)
+	case "labels":
+		trainLabels(dev, test)
+	case "crossvalidate":
+		crossValidateBlink(r, append(dev, validation...))
+	case "report":
+		reportModel(r, dev, validation)
+	case "search":
+		searchBlink(r, append(dev, validation...))
+	default:
+		log.Fatalf("Unknown mode \"%s\"", *mode)
+	}
+}
+
+// trainBlink boosts decision trees which predict whether an issue is
+// labelled Cr-Blink, until -max-rounds, -patience or -budget stops
+// it, keeping the round which did best on the validation issues.
+func trainBlink(r *rand.Rand, dev []ml.Example, validation []ml.Example, test []ml.Example) {
 	// TODO: Remove this. Shrunk to get profiling results.
 	//dev = dev[0:1000]
 	//test = test[0:1000]
-	dev = trimToBalanceClasses(r, dev, 3)
-	debugCountLabelOccurrence("trimmed dev", dev)
+	if !ml.IsEvaluationMetric(*stopMetric) {
+		log.Fatalf("Unknown metric \"%s\"; use -stop-metric with one of %v", *stopMetric, ml.EvaluationMetrics)
+	}
+	switch *calibration {
+	case "none", "platt", "isotonic":
+	default:
+		log.Fatalf("Unknown calibration method \"%s\"", *calibration)
+	}
+	m := extractFeatures(dev)
+	dev = m.Examples
 
 	// Build features.
-	features := extractFeatures(dev)
+	features := withoutTarget(m.Features, blinkLabel)
 	fmt.Printf("%d features: %v, %v, %v, ...\n", len(features), features[0], features[1], features[2])
 
 	// Build a decision tree.
 	// stumper := ml.NewDecisionStumper(features, dev, r)
-	maxDecisionTreeDepth := 3
-	treeBuilder := ml.NewDecisionTreeBuilder(features, maxDecisionTreeDepth)
-	booster := ml.NewAdaBoost(dev, treeBuilder, r)
+	treeBuilder := ml.NewDecisionTreeBuilder(features, flagLearnerConfig().treeDepth(3))
+	treeBuilder.Parallelism = *parallelism
+	treeBuilder.Numeric, treeBuilder.Categorical = metadataFeatures(blinkLabel)
+	treeBuilder.GainRatio = *gainRatio
+	var learner ml.Learner = treeBuilder
+	var prunedTreeBuilder *ml.PrunedTreeBuilder
+	switch *prune {
+	case "none":
+	case "reduced-error":
+		prunedTreeBuilder = ml.NewPrunedTreeBuilder(treeBuilder, ml.ReducedErrorPruning)
+	case "pessimistic":
+		prunedTreeBuilder = ml.NewPrunedTreeBuilder(treeBuilder, ml.PessimisticPruning)
+	default:
+		log.Fatalf("Unknown pruning method \"%s\"", *prune)
+	}
+	if prunedTreeBuilder != nil {
+		if *reweight {
+			log.Fatal("Pruned trees can not be learned from weighted examples; use -reweight=false")
+		}
+		learner = prunedTreeBuilder
+	}
+	booster := ml.NewAdaBoostOnMatrix(m, learner, r)
+	booster.Parallelism = *parallelism
+	booster.Reweight = *reweight
+	booster.SetInitialDistribution(ml.PriorDistribution(dev, *positivePrior))
+	booster.Costs = classCosts()
+	if *loadPath != "" {
+		var err error
+		booster, err = loadModel(*loadPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		booster.SetInitialDistribution(ml.PriorDistribution(dev, *positivePrior))
+		booster.Costs = classCosts()
+		booster.Resume(dev, learner, r)
+		booster.Matrix = m
+		booster.Parallelism = *parallelism
+		booster.Reweight = *reweight
+		fmt.Printf("loaded %d rounds from %s\n", len(booster.H), *loadPath)
+	}
 
-	for i := 0; ; i++ {
-		booster.Round(1000)
+	policy := &ml.StoppingPolicy{
+		MaxRounds:  *maxRounds,
+		Budget:     *budget,
+		Validation: validation,
+		Metric:     *stopMetric,
+		Patience:   *patience,
+	}
+	report := booster.Train(*sampleSize, policy, func(rounds int, validation *ml.Evaluation) {
+		i := rounds - 1
 		fmt.Printf("%d: dev=%f test=%f a=%f\n", i, booster.Evaluate(dev), booster.Evaluate(test), booster.A[i])
 		debugDumpExampleWeights(booster)
+		if prunedTreeBuilder != nil {
+			trees, removed := prunedTreeBuilder.Pruned()
+			fmt.Printf("pruned %d nodes from %d trees\n", removed, trees)
+		}
+		fmt.Printf("validation %s=%f\n", *stopMetric, validation.Metric(*stopMetric))
+		ev := ml.Evaluate(booster, test)
+		fmt.Printf("test %v\n", ev)
+		if *evaluationPath != "" {
+			if err := writeEvaluation(*evaluationPath, ev); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if *savePath != "" {
+			if err := saveModel(*savePath, booster); err != nil {
+				log.Fatal(err)
+			}
+		}
+	})
+	fmt.Printf("%v\n", report)
+
+	// Save and evaluate the model as of the best round.
+	explainTopIssues(booster, test, *explain)
+	model := calibrate(booster, validation, test)
+	ev := ml.Evaluate(model, test)
+	fmt.Printf("test %v\n", ev)
+	if *evaluationPath != "" {
+		if err := writeEvaluation(*evaluationPath, ev); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *savePath != "" {
+		if err := saveModel(*savePath, model); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// calibrate fits the calibrator the -calibrate flag names to c's
+// scores for the validation examples, and reports how well
+// calibrated c is on the test examples before and after. It returns
+// c itself if -calibrate is none.
+func calibrate(c ml.Classifier, validation []ml.Example, test []ml.Example) ml.Classifier {
+	var calibrator ml.Calibrator
+	switch *calibration {
+	case "none":
+		return c
+	case "platt":
+		calibrator = ml.FitPlatt(c, validation)
+	case "isotonic":
+		calibrator = ml.FitIsotonic(c, validation)
+	default:
+		log.Fatalf("Unknown calibration method \"%s\"", *calibration)
+	}
+	calibrated := &ml.CalibratedClassifier{Classifier: c, Calibrator: calibrator}
+	fmt.Printf("test reliability before calibration %v\n", ml.ReliabilityDiagram(c, test, 10))
+	reliability := ml.ReliabilityDiagram(calibrated, test, 10)
+	fmt.Printf("test reliability after %s calibration %v\n", *calibration, reliability)
+	if *reliabilityPath != "" {
+		f, err := os.Create(*reliabilityPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := reliability.WriteJSON(f); err != nil {
+			log.Fatalf("Writing %s: %v", *reliabilityPath, err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatal(err)
+		}
 	}
+	return calibrated
 }
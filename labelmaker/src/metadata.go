@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"ml"
+	"sort"
+	"strings"
+	"time"
+)
+
+// numericFeatures are issue metadata with numeric values, by name.
+// They return NaN when the value is missing.
+var numericFeatures = map[string]func(i *IssueExample) float64{
+	"content-length": func(i *IssueExample) float64 {
+		return float64(len(i.Content))
+	},
+	"stars": func(i *IssueExample) float64 {
+		return float64(i.Stars)
+	},
+	"cc": func(i *IssueExample) float64 {
+		return float64(len(i.CC))
+	},
+	// How many days the issue was open for.
+	"age": func(i *IssueExample) float64 {
+		if i.Published.IsZero() || i.Closed.IsZero() {
+			return math.NaN()
+		}
+		return i.Closed.Sub(i.Published).Hours() / 24.0
+	},
+	// When the issue was reported and closed, in days since 1970, so
+	// that trees can find periods when a component was busy.
+	"published": func(i *IssueExample) float64 {
+		return daysSinceEpoch(i.Published)
+	},
+	"closed": func(i *IssueExample) float64 {
+		return daysSinceEpoch(i.Closed)
+	},
+}
+
+// daysSinceEpoch returns the days from 1970 to t, or NaN if t is the
+// zero time.
+func daysSinceEpoch(t time.Time) float64 {
+	if t.IsZero() {
+		return math.NaN()
+	}
+	return float64(t.Unix()) / (24.0 * 60.0 * 60.0)
+}
+
+// numericFeature is a numeric feature for splitting on at a threshold
+// with ml.DecisionTreeBuilder.Numeric.
+type numericFeature struct {
+	name string
+}
+
+func (f *numericFeature) String() string {
+	return f.name
+}
+
+func (f *numericFeature) Predict(e ml.Example) float64 {
+	return numericFeatures[f.name](issueExampleOf(e))
+}
+
+// labelValueFeature is the value of labels with a prefix, eg Mac for
+// OS-Mac. If an issue has several labels with the prefix, the first
+// value alphabetically is used.
+type labelValueFeature struct {
+	prefix string
+}
+
+func (f *labelValueFeature) String() string {
+	return f.prefix + "-*"
+}
+
+func (f *labelValueFeature) Category(e ml.Example) string {
+	var values []string
+	for label := range issueExampleOf(e).IssueLabels {
+		if strings.HasPrefix(label, f.prefix+"-") {
+			values = append(values, strings.TrimPrefix(label, f.prefix+"-"))
+		}
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	sort.Strings(values)
+	return values[0]
+}
+
+// authorDomainFeature is the domain of the issue's author, eg
+// chromium.org, or "" if it is unknown.
+type authorDomainFeature struct{}
+
+func (f *authorDomainFeature) String() string {
+	return "author-domain"
+}
+
+func (f *authorDomainFeature) Category(e ml.Example) string {
+	author := issueExampleOf(e).Author
+	if at := strings.LastIndex(author, "@"); at >= 0 {
+		return strings.ToLower(author[at+1:])
+	}
+	return ""
+}
+
+// labelFeature is whether an issue has a label. Models must not see
+// the labels they predict; see withoutTarget.
+type labelFeature struct {
+	label string
+}
+
+func (f *labelFeature) String() string {
+	return fmt.Sprintf("label*%s", f.label)
+}
+
+func (f *labelFeature) Predict(e ml.Example) float64 {
+	if issueExampleOf(e).IssueLabels[f.label] {
+		return 1.0
+	}
+	return -1.0
+}
+
+// labelKind returns the first dash-separated part of a label, eg Cr
+// for Cr-Blink-Layout.
+func labelKind(label string) string {
+	return labelFamily(label, 1)
+}
+
+// givesAway returns whether labels of the given kind give away the
+// target label family. A label of the target's own kind does: Cr-Blink
+// implies Cr-Blink-Layout is likely, and Cr-Platform that Cr-Blink
+// is not. Target "" is no label.
+func givesAway(kind string, target string) bool {
+	return target != "" && kind == labelKind(target)
+}
+
+// withoutTarget returns the features, leaving out those of labels
+// which give away target, so that models of it can not learn from
+// the very labels they predict.
+func withoutTarget(features []ml.Feature, target string) []ml.Feature {
+	var kept []ml.Feature
+	for _, f := range features {
+		if l, ok := f.(*labelFeature); ok && givesAway(labelKind(l.label), target) {
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return kept
+}
+
+// labelFeaturePostings returns a feature for each label at least
+// minExamples of the examples have, in alphabetical order, and the
+// examples which have it.
+func labelFeaturePostings(examples []ml.Example, minExamples int) ([]ml.Feature, [][]int) {
+	postings := make(map[string][]int)
+	for j, e := range examples {
+		for label := range issueExampleOf(e).IssueLabels {
+			postings[label] = append(postings[label], j)
+		}
+	}
+	var labels []string
+	for label, posting := range postings {
+		if len(posting) >= minExamples {
+			labels = append(labels, label)
+		}
+	}
+	sort.Strings(labels)
+	features := make([]ml.Feature, len(labels))
+	featurePostings := make([][]int, len(labels))
+	for k, label := range labels {
+		features[k] = &labelFeature{label}
+		featurePostings[k] = postings[label]
+	}
+	return features, featurePostings
+}
+
+func init() {
+	ml.RegisterFeature("numeric", &numericFeature{}, func(f ml.Feature) (interface{}, error) {
+		return f.(*numericFeature).name, nil
+	}, func(data json.RawMessage) (ml.Feature, error) {
+		var name string
+		if err := json.Unmarshal(data, &name); err != nil {
+			return nil, err
+		}
+		if _, ok := numericFeatures[name]; !ok {
+			return nil, fmt.Errorf("Unknown numeric feature \"%s\"", name)
+		}
+		return &numericFeature{name}, nil
+	})
+	ml.RegisterFeature("label", &labelFeature{}, func(f ml.Feature) (interface{}, error) {
+		return f.(*labelFeature).label, nil
+	}, func(data json.RawMessage) (ml.Feature, error) {
+		var label string
+		err := json.Unmarshal(data, &label)
+		return &labelFeature{label}, err
+	})
+	ml.RegisterCategoricalFeature("author-domain", &authorDomainFeature{}, func(f ml.CategoricalFeature) (interface{}, error) {
+		return nil, nil
+	}, func(data json.RawMessage) (ml.CategoricalFeature, error) {
+		return &authorDomainFeature{}, nil
+	})
+	ml.RegisterCategoricalFeature("label-value", &labelValueFeature{}, func(f ml.CategoricalFeature) (interface{}, error) {
+		return f.(*labelValueFeature).prefix, nil
+	}, func(data json.RawMessage) (ml.CategoricalFeature, error) {
+		var prefix string
+		err := json.Unmarshal(data, &prefix)
+		return &labelValueFeature{prefix}, err
+	})
+}
+
+// metadataFeatures returns the numeric and categorical features of
+// issue metadata, in a fixed order, leaving out the values of labels
+// which give away target.
+func metadataFeatures(target string) ([]ml.Feature, []ml.CategoricalFeature) {
+	var names []string
+	for name := range numericFeatures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	numeric := make([]ml.Feature, len(names))
+	for i, name := range names {
+		numeric[i] = &numericFeature{name}
+	}
+	var categorical []ml.CategoricalFeature
+	for _, prefix := range []string{"OS", "Pri", "Type"} {
+		if !givesAway(prefix, target) {
+			categorical = append(categorical, &labelValueFeature{prefix})
+		}
+	}
+	categorical = append(categorical, &authorDomainFeature{})
+	return numeric, categorical
+}
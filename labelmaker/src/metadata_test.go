@@ -0,0 +1,60 @@
+package main
+
+import (
+	"issues"
+	"ml"
+	"testing"
+)
+
+// featureNames returns the names of numeric, binary and categorical
+// features.
+func featureNames(features []ml.Feature, categorical []ml.CategoricalFeature) map[string]bool {
+	names := make(map[string]bool)
+	for _, f := range features {
+		names[f.String()] = true
+	}
+	for _, f := range categorical {
+		names[f.String()] = true
+	}
+	return names
+}
+
+func TestWithoutTarget(t *testing.T) {
+	examples := []ml.Example{
+		NewIssueExample(&issues.Issue{Id: 1, Title: "crash in layout", IssueLabels: issues.Labels{"Cr-Blink": true, "Cr-Blink-Layout": true, "OS-Mac": true, "Type-Bug": true}, Author: "someone@chromium.org"}),
+		NewIssueExample(&issues.Issue{Id: 2, Title: "crash in tabs", IssueLabels: issues.Labels{"Cr-UI": true, "OS-Linux": true, "Type-Feature": true}, Author: "else...@gmail.com"}),
+	}
+	m := extractFeaturesWithMinSupport(examples, 0.0)
+
+	for _, c := range []struct {
+		target string
+		// gone are features which give the target away, and kept
+		// features which do not.
+		gone []string
+		kept []string
+	}{
+		// Blink mode, and labels mode with -label-depth 2.
+		{blinkLabel, []string{"label*Cr-Blink", "label*Cr-Blink-Layout", "label*Cr-UI"}, []string{"title*crash", "label*OS-Mac", "label*Type-Bug", "OS-*", "Type-*", "Pri-*", "author-domain"}},
+		// Labels mode with -label-depth 1.
+		{"OS", []string{"label*OS-Mac", "label*OS-Linux", "OS-*"}, []string{"title*crash", "label*Cr-Blink", "label*Type-Bug", "Type-*", "Pri-*", "author-domain"}},
+	} {
+		numeric, categorical := metadataFeatures(c.target)
+		names := featureNames(append(withoutTarget(m.Features, c.target), numeric...), categorical)
+		for _, name := range c.gone {
+			if names[name] {
+				t.Errorf("expected %s to be left out of the features for %s", name, c.target)
+			}
+		}
+		for _, name := range c.kept {
+			if !names[name] {
+				t.Errorf("expected %s to be kept in the features for %s but were %v", name, c.target, names)
+			}
+		}
+	}
+
+	// Without a target every label is a feature.
+	names := featureNames(withoutTarget(m.Features, ""), nil)
+	if !names["label*Cr-Blink"] || !names["label*OS-Mac"] {
+		t.Errorf("expected every label to be kept without a target but were %v", names)
+	}
+}
@@ -0,0 +1,377 @@
+package ml
+
+import (
+	"math"
+	"sort"
+)
+
+// A CategoricalFeature takes one of several unordered values, like the
+// operating system an issue was reported on.
+type CategoricalFeature interface {
+	// String returns a human-readable description of the feature.
+	String() string
+	// Category returns the feature's value for the example, or "" if
+	// the value is missing.
+	Category(Example) string
+}
+
+// ThresholdNode tests whether a numeric feature is above a threshold.
+// Numeric features are Features whose Predict returns the value of
+// the feature, or NaN if the value is missing.
+type ThresholdNode struct {
+	feature   Feature
+	threshold float64
+	below     Classifier
+	above     Classifier
+	// pAbove is the fraction of the training examples with a value
+	// which were above the threshold. Examples with a missing value
+	// are predicted by the sign of both branches' votes, weighted by
+	// these fractions.
+	pAbove float64
+}
+
+// vote returns the sign of a blended prediction as ±1, so that trees
+// keep to the Classifier contract wherever they are used.
+func vote(score float64) float64 {
+	if math.Signbit(score) {
+		return -1.0
+	}
+	return 1.0
+}
+
+func (n *ThresholdNode) Predict(e Example) float64 {
+	v := n.feature.Predict(e)
+	switch {
+	case math.IsNaN(v):
+		return vote(n.pAbove*n.above.Predict(e) + (1.0-n.pAbove)*n.below.Predict(e))
+	case v > n.threshold:
+		return n.above.Predict(e)
+	default:
+		return n.below.Predict(e)
+	}
+}
+
+// CategoryNode has a branch for each value of a categorical feature.
+type CategoryNode struct {
+	feature    CategoricalFeature
+	categories []string
+	children   []Classifier
+	// weights is the fraction of the training examples with a value
+	// which had each category. Examples with a missing or unseen
+	// value are predicted by the sign of every branch's vote,
+	// weighted by these fractions.
+	weights []float64
+}
+
+func (n *CategoryNode) Predict(e Example) float64 {
+	c := n.feature.Category(e)
+	if i := sort.SearchStrings(n.categories, c); c != "" && i < len(n.categories) && n.categories[i] == c {
+		return n.children[i].Predict(e)
+	}
+	sum := 0.0
+	for i, child := range n.children {
+		sum += n.weights[i] * child.Predict(e)
+	}
+	return vote(sum)
+}
+
+// treeData holds the values of every feature for the examples a tree
+// is built from.
+type treeData struct {
+	m       *FeatureMatrix
+	columns []Bitset
+	// numeric[f][i] is the value of the builder's fth numeric feature
+	// for example i.
+	numeric [][]float64
+	// categorical[f][i] is the category of the builder's fth
+	// categorical feature for example i.
+	categorical [][]string
+	// weights, if not nil, are the weights of the examples.
+	// Otherwise each example counts once.
+	weights []float64
+}
+
+// weight returns the total weight of the examples in b.
+func (d *treeData) weight(b Bitset) float64 {
+	if d.weights == nil {
+		return float64(b.Count())
+	}
+	w := 0.0
+	b.ForEach(func(i int) {
+		w += d.weights[i]
+	})
+	return w
+}
+
+// exampleWeight returns the weight of example i.
+func (d *treeData) exampleWeight(i int) float64 {
+	if d.weights == nil {
+		return 1.0
+	}
+	return d.weights[i]
+}
+
+// andWeight returns the total weight of the examples in both b and
+// c.
+func (d *treeData) andWeight(b Bitset, c Bitset) float64 {
+	if d.weights == nil {
+		return float64(b.AndCount(c))
+	}
+	return d.weight(b.And(c))
+}
+
+func (d *treeData) andWeight3(b Bitset, c Bitset, e Bitset) float64 {
+	if d.weights == nil {
+		return float64(b.AndCount3(c, e))
+	}
+	return d.weight(b.And(c).And(e))
+}
+
+// andNotWeight returns the total weight of the examples in b but not
+// c.
+func (d *treeData) andNotWeight(b Bitset, c Bitset) float64 {
+	if d.weights == nil {
+		return float64(b.Count() - b.AndCount(c))
+	}
+	return d.weight(b.AndNot(c))
+}
+
+type splitKind int
+
+const (
+	featureSplit splitKind = iota
+	thresholdSplit
+	categorySplit
+)
+
+// split is a candidate test at a tree node.
+type split struct {
+	kind splitKind
+	// index is the index of the feature in the builder's features,
+	// numeric features or categorical features, depending on kind.
+	index     int
+	threshold float64
+	gain      float64
+	splitInfo float64
+}
+
+// chooseSplit returns the candidate with the best information gain,
+// or with gainRatio, the best gain ratio. Like C4.5, the gain ratio
+// is only used to choose between candidates with at least the average
+// gain, because a candidate with a tiny split info can have a high
+// gain ratio without being useful. Ties go to the earliest candidate.
+// Candidates must have positive gain.
+func chooseSplit(candidates []split, gainRatio bool) *split {
+	var best *split
+	if !gainRatio {
+		for i := range candidates {
+			if best == nil || candidates[i].gain > best.gain {
+				best = &candidates[i]
+			}
+		}
+		return best
+	}
+
+	sum := 0.0
+	for _, c := range candidates {
+		sum += c.gain
+	}
+	average := sum / float64(len(candidates))
+	bestRatio := 0.0
+	for i, c := range candidates {
+		// Allow for rounding error in the average.
+		if c.gain < average-1e-12 || c.splitInfo == 0.0 {
+			continue
+		}
+		if ratio := c.gain / c.splitInfo; best == nil || ratio > bestRatio {
+			best, bestRatio = &candidates[i], ratio
+		}
+	}
+	return best
+}
+
+type valueLabel struct {
+	value  float64
+	label  bool
+	weight float64
+}
+
+// thresholdSplits returns the best threshold for each numeric feature
+// which has one with positive gain. Gain is computed as in C4.5: the
+// gain over the examples with a known value is scaled by the fraction
+// of examples which have one, and penalized by log2(N-1)/|D| for
+// choosing between N-1 thresholds. Fractions are of the weight w of
+// the examples, but |D| is the number of them.
+func (tb *DecisionTreeBuilder) thresholdSplits(d *treeData, examples Bitset, w float64) []split {
+	n := examples.Count()
+	var splits []split
+	for f, values := range d.numeric {
+		var known []valueLabel
+		examples.ForEach(func(i int) {
+			if v := values[i]; !math.IsNaN(v) {
+				known = append(known, valueLabel{v, d.m.labels.Has(i), d.exampleWeight(i)})
+			}
+		})
+		if len(known) < 2 {
+			continue
+		}
+		sort.Slice(known, func(i, j int) bool {
+			return known[i].value < known[j].value
+		})
+
+		wknown, wknownPos := 0.0, 0.0
+		for _, k := range known {
+			wknown += k.weight
+			if k.label {
+				wknownPos += k.weight
+			}
+		}
+		knownInfo := info(wknownPos, wknown-wknownPos)
+
+		distinct := 1
+		bestGain, bestThreshold, bestBelow := 0.0, 0.0, -1.0
+		below, belowPos := 0.0, 0.0
+		for i := 0; i < len(known)-1; i++ {
+			below += known[i].weight
+			if known[i].label {
+				belowPos += known[i].weight
+			}
+			if known[i].value == known[i+1].value {
+				continue
+			}
+			distinct++
+			above, abovePos := wknown-below, wknownPos-belowPos
+			pBelow := below / wknown
+			gain := knownInfo - pBelow*info(belowPos, below-belowPos) - (1.0-pBelow)*info(abovePos, above-abovePos)
+			if bestBelow == -1.0 || gain > bestGain {
+				bestGain, bestThreshold, bestBelow = gain, known[i].value, below
+			}
+		}
+		if bestBelow == -1.0 {
+			continue
+		}
+
+		gain := wknown/w*bestGain - math.Log2(float64(distinct-1))/float64(n)
+		if gain > 0.0 {
+			splitInfo := weightedEntropy([]float64{bestBelow, wknown - bestBelow, w - wknown})
+			splits = append(splits, split{thresholdSplit, f, bestThreshold, gain, splitInfo})
+		}
+	}
+	return splits
+}
+
+// categoryExamples partitions the examples with a value of categorical
+// feature f by category, returning the categories in order, the
+// examples with each category and the weight of the examples with a
+// value.
+func categoryExamples(d *treeData, f int, examples Bitset) ([]string, []Bitset, float64) {
+	byCategory := make(map[string]Bitset)
+	nknown := 0.0
+	examples.ForEach(func(i int) {
+		c := d.categorical[f][i]
+		if c == "" {
+			return
+		}
+		if byCategory[c] == nil {
+			byCategory[c] = NewBitset(len(d.m.Examples))
+		}
+		byCategory[c].Set(i)
+		nknown += d.exampleWeight(i)
+	})
+	var categories []string
+	for c := range byCategory {
+		categories = append(categories, c)
+	}
+	sort.Strings(categories)
+	subsets := make([]Bitset, len(categories))
+	for i, c := range categories {
+		subsets[i] = byCategory[c]
+	}
+	return categories, subsets, nknown
+}
+
+// categorySplits returns a split for each categorical feature which
+// has at least two categories among the examples and positive gain.
+func (tb *DecisionTreeBuilder) categorySplits(d *treeData, examples Bitset, w float64) []split {
+	var splits []split
+	for f := range d.categorical {
+		categories, subsets, nknown := categoryExamples(d, f, examples)
+		if len(categories) < 2 {
+			continue
+		}
+		nknownPos := 0.0
+		infoThisFeature := 0.0
+		sizes := make([]float64, len(categories)+1)
+		for i, subset := range subsets {
+			size, pos := d.weight(subset), d.andWeight(subset, d.m.labels)
+			nknownPos += pos
+			infoThisFeature += size / nknown * info(pos, size-pos)
+			sizes[i] = size
+		}
+		sizes[len(categories)] = w - nknown
+
+		gain := nknown / w * (info(nknownPos, nknown-nknownPos) - infoThisFeature)
+		if gain > 0.0 {
+			splits = append(splits, split{categorySplit, f, 0.0, gain, weightedEntropy(sizes)})
+		}
+	}
+	return splits
+}
+
+// buildThresholdNode splits the examples at s. Examples with a
+// missing value go down the branch with more weight.
+func (tb *DecisionTreeBuilder) buildThresholdNode(depth int, d *treeData, examples Bitset, s *split) Classifier {
+	values := d.numeric[s.index]
+	below, above := NewBitset(len(d.m.Examples)), NewBitset(len(d.m.Examples))
+	var missing []int
+	examples.ForEach(func(i int) {
+		switch v := values[i]; {
+		case math.IsNaN(v):
+			missing = append(missing, i)
+		case v > s.threshold:
+			above.Set(i)
+		default:
+			below.Set(i)
+		}
+	})
+	nbelow, nabove := d.weight(below), d.weight(above)
+	larger := below
+	if nabove > nbelow {
+		larger = above
+	}
+	for _, i := range missing {
+		larger.Set(i)
+	}
+	return &ThresholdNode{
+		tb.Numeric[s.index],
+		s.threshold,
+		tb.build(depth+1, d, below),
+		tb.build(depth+1, d, above),
+		nabove / (nbelow + nabove),
+	}
+}
+
+// buildCategoryNode splits the examples at s. Examples with a missing
+// value go down the branch with the most weight.
+func (tb *DecisionTreeBuilder) buildCategoryNode(depth int, d *treeData, examples Bitset, s *split) Classifier {
+	categories, subsets, nknown := categoryExamples(d, s.index, examples)
+	weights := make([]float64, len(categories))
+	largest := 0
+	for i, subset := range subsets {
+		weights[i] = d.weight(subset) / nknown
+		if weights[i] > weights[largest] {
+			largest = i
+		}
+	}
+	missing := examples.AndNot(subsets[0])
+	for _, subset := range subsets[1:] {
+		missing = missing.AndNot(subset)
+	}
+	subsets[largest] = subsets[largest].Or(missing)
+
+	children := make([]Classifier, len(categories))
+	for i, subset := range subsets {
+		children[i] = tb.build(depth+1, d, subset)
+	}
+	return &CategoryNode{tb.Categorical[s.index], categories, children, weights}
+}
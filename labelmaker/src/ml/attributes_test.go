@@ -0,0 +1,186 @@
+package ml
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+type attributeDatum struct {
+	size  float64
+	shape string
+	label Label
+}
+
+func (d *attributeDatum) Label() Label {
+	return d.label
+}
+
+type sizeFeature struct{}
+
+func (sizeFeature) String() string {
+	return "size"
+}
+
+func (sizeFeature) Predict(e Example) float64 {
+	return e.(*attributeDatum).size
+}
+
+type shapeFeature struct{}
+
+func (shapeFeature) String() string {
+	return "shape"
+}
+
+func (shapeFeature) Category(e Example) string {
+	return e.(*attributeDatum).shape
+}
+
+func init() {
+	RegisterFeature("size", sizeFeature{}, func(f Feature) (interface{}, error) {
+		return nil, nil
+	}, func(data json.RawMessage) (Feature, error) {
+		return sizeFeature{}, nil
+	})
+	RegisterCategoricalFeature("shape", shapeFeature{}, func(f CategoricalFeature) (interface{}, error) {
+		return nil, nil
+	}, func(data json.RawMessage) (CategoricalFeature, error) {
+		return shapeFeature{}, nil
+	})
+}
+
+func TestDecisionTreeThresholdSplit(t *testing.T) {
+	dataset := []Example{
+		&attributeDatum{1.0, "", false},
+		&attributeDatum{2.0, "", false},
+		&attributeDatum{3.0, "", false},
+		&attributeDatum{10.0, "", true},
+		&attributeDatum{11.0, "", true},
+		&attributeDatum{math.NaN(), "", true},
+	}
+	tb := NewDecisionTreeBuilder(nil, 3)
+	tb.Numeric = []Feature{sizeFeature{}}
+	tree := tb.NewClassifier(dataset)
+
+	n, ok := tree.(*ThresholdNode)
+	if !ok {
+		t.Fatalf("expected a threshold node but was %T", tree)
+	}
+	if n.threshold != 3.0 {
+		t.Errorf("expected threshold 3 but was %f", n.threshold)
+	}
+	if n.pAbove != 0.4 {
+		t.Errorf("expected 0.4 of the examples to be above the threshold but was %f", n.pAbove)
+	}
+	for i, example := range dataset[:5] {
+		if Label(tree.Predict(example) > 0.0) != example.Label() {
+			t.Errorf("expected tree to classify example %d as %v", i, example.Label())
+		}
+	}
+	// An example with a missing value is predicted by the sign of
+	// both branches' votes.
+	if p := tree.Predict(&attributeDatum{math.NaN(), "", false}); p != -1.0 {
+		t.Errorf("expected a missing value to predict -1 but was %f", p)
+	}
+}
+
+// Boosting trees which blend their branches for missing values must
+// weight the examples the same way when the distribution is
+// recomputed from the model, whether or not it boosts over a matrix.
+func TestBoostingTreesWithMissingValues(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	var dataset []Example
+	for i := 0; i < 200; i++ {
+		d := &attributeDatum{r.Float64() * 10.0, "", Label(r.Intn(3) > 0)}
+		if d.label && r.Intn(2) == 0 {
+			d.size += 5.0
+		}
+		if r.Intn(4) == 0 {
+			d.size = math.NaN()
+		}
+		dataset = append(dataset, d)
+	}
+	tb := NewDecisionTreeBuilder(nil, 2)
+	tb.Numeric = []Feature{sizeFeature{}}
+	for _, a := range []*AdaBoost{
+		NewAdaBoost(dataset, tb, rand.New(rand.NewSource(42))),
+		NewAdaBoostOnMatrix(NewFeatureMatrix(nil, dataset), tb, rand.New(rand.NewSource(42))),
+	} {
+		a.Reweight = true
+		for i := 0; i < 5; i++ {
+			a.Round(len(dataset))
+		}
+		for i, e := range dataset {
+			if p := a.H[0].Predict(e); p != 1.0 && p != -1.0 {
+				t.Fatalf("expected the tree to predict ±1 for example %d but was %f", i, p)
+			}
+		}
+		p := append([]float64(nil), a.D.P...)
+		a.recomputeDistribution()
+		for i := range p {
+			if math.Abs(p[i]-a.D.P[i]) > 1e-9 {
+				t.Fatalf("expected recomputed weight of example %d to be %g but was %g", i, p[i], a.D.P[i])
+			}
+		}
+	}
+}
+
+func TestDecisionTreeCategorySplit(t *testing.T) {
+	dataset := []Example{
+		&attributeDatum{0.0, "round", true},
+		&attributeDatum{0.0, "round", true},
+		&attributeDatum{0.0, "square", false},
+		&attributeDatum{0.0, "square", false},
+		&attributeDatum{0.0, "oval", true},
+		&attributeDatum{0.0, "", true},
+	}
+	tb := NewDecisionTreeBuilder(nil, 3)
+	tb.Categorical = []CategoricalFeature{shapeFeature{}}
+	tb.GainRatio = true
+	tree := tb.NewClassifier(dataset)
+
+	n, ok := tree.(*CategoryNode)
+	if !ok {
+		t.Fatalf("expected a category node but was %T", tree)
+	}
+	if len(n.categories) != 3 {
+		t.Errorf("expected 3 categories but was %v", n.categories)
+	}
+	for i, example := range dataset[:5] {
+		if Label(tree.Predict(example) > 0.0) != example.Label() {
+			t.Errorf("expected tree to classify example %d as %v", i, example.Label())
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := SaveModel(&buf, tree); err != nil {
+		t.Fatalf("should have saved the tree: %v", err)
+	}
+	loaded, err := LoadModel(&buf)
+	if err != nil {
+		t.Fatalf("should have loaded the tree: %v", err)
+	}
+	for i, example := range dataset {
+		if expected, actual := tree.Predict(example), loaded.Predict(example); expected != actual {
+			t.Errorf("expected loaded tree to predict %f for example %d but was %f", expected, i, actual)
+		}
+	}
+}
+
+func TestChooseSplitByGainRatio(t *testing.T) {
+	candidates := []split{
+		{featureSplit, 0, 0.0, 0.5, 1.0},
+		{categorySplit, 0, 0.0, 0.6, 3.0},
+		{featureSplit, 1, 0.0, 0.1, 0.1},
+	}
+	if best := chooseSplit(candidates, false); best != &candidates[1] {
+		t.Errorf("expected the split with the most gain but was %v", *best)
+	}
+	// The third split has the highest gain ratio but less than the
+	// average gain.
+	if best := chooseSplit(candidates, true); best != &candidates[0] {
+		t.Errorf("expected the split with the best gain ratio but was %v", *best)
+	}
+}
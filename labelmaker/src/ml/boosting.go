@@ -10,6 +10,14 @@ type Learner interface {
 	NewClassifier([]Example) Classifier
 }
 
+// A WeightedLearner can learn from weighted examples directly,
+// instead of from a sample drawn according to the weights.
+type WeightedLearner interface {
+	// NewWeightedClassifier learns from examples with the given
+	// weights, which sum to one.
+	NewWeightedClassifier(examples []Example, weights []float64) Classifier
+}
+
 type Classifier interface {
 	// Returns -1.0 for the negative class, and 1.0 for the positive class.
 	Predict(Example) float64
@@ -22,6 +30,26 @@ type AdaBoost struct {
 	H       []Classifier
 	A       []float64
 	rand    *rand.Rand
+	// Matrix, if set, holds the features of Examples. Learners
+	// which are MatrixLearners learn from it directly.
+	Matrix *FeatureMatrix
+	// Parallelism is how many goroutines evaluate and reweight the
+	// examples each round. The model built does not depend on it.
+	Parallelism int
+	// Reweight gives the weak learner every example with its weight
+	// in D, instead of a sample drawn according to D. The learner
+	// must be a WeightedLearner, or a WeightedMatrixLearner if
+	// Matrix is set.
+	Reweight bool
+	// Initial, if set, is the distribution boosting started from;
+	// see SetInitialDistribution.
+	Initial *Distribution
+	// Costs, if set, makes boosting cost-sensitive, as AdaC2 in Sun
+	// et al., "Cost-sensitive boosting for classification of
+	// imbalanced data", 2007: each round's error is weighted by the
+	// costs, and each example's weight is scaled by its cost every
+	// round.
+	Costs *Costs
 }
 
 func NewAdaBoost(es []Example, learner Learner, r *rand.Rand) *AdaBoost {
@@ -33,7 +61,49 @@ func NewAdaBoost(es []Example, learner Learner, r *rand.Rand) *AdaBoost {
 		nil,
 		nil,
 		r,
+		nil,
+		1,
+		false,
+		nil,
+		nil,
+	}
+}
+
+// NewAdaBoostOnMatrix boosts over the examples of m.
+func NewAdaBoostOnMatrix(m *FeatureMatrix, learner Learner, r *rand.Rand) *AdaBoost {
+	a := NewAdaBoost(m.Examples, learner, r)
+	a.Matrix = m
+	return a
+}
+
+// AdaBoostLearner is a Learner which boosts a weak learner for a
+// fixed number of rounds, sampling SampleSize examples each round
+// unless Reweight is set.
+type AdaBoostLearner struct {
+	Weak        Learner
+	Rounds      int
+	SampleSize  int
+	Rand        *rand.Rand
+	Parallelism int
+	Reweight    bool
+	// Prior, if not 0, is the initial total weight of the positive
+	// examples; see PriorDistribution.
+	Prior float64
+	Costs *Costs
+}
+
+func (l *AdaBoostLearner) NewClassifier(es []Example) Classifier {
+	a := NewAdaBoost(es, l.Weak, l.Rand)
+	a.Parallelism = l.Parallelism
+	a.Reweight = l.Reweight
+	if l.Prior != 0.0 {
+		a.SetInitialDistribution(PriorDistribution(es, l.Prior))
 	}
+	a.Costs = l.Costs
+	for i := 0; i < l.Rounds; i++ {
+		a.Round(l.SampleSize)
+	}
+	return a
 }
 
 func float64OfLabel(label Label) float64 {
@@ -46,39 +116,101 @@ func float64OfLabel(label Label) float64 {
 
 // Evaluates Classifier c and on examples and returns the error rate, 0.0-1.0.
 func evaluateClassifier(c Classifier, examples []Example) float64 {
-	return evaluateClassifierWeighted(c, examples, UniformDistribution(len(examples)))
+	return evaluateClassifierWeighted(c, examples, UniformDistribution(len(examples)), 1)
 }
 
-func evaluateClassifierWeighted(c Classifier, examples []Example, d *Distribution) float64 {
-	misclassifications := 0.0
-	for i, example := range examples {
-		if !math.Signbit(c.Predict(example)) != bool(example.Label()) {
-			misclassifications += d.P[i]
+// evaluateClassifierWeighted returns the total weight of the examples
+// c misclassifies, predicting them with up to parallelism goroutines.
+func evaluateClassifierWeighted(c Classifier, examples []Example, d *Distribution, parallelism int) float64 {
+	return parallelSum(len(examples), parallelism, func(i int) float64 {
+		if !math.Signbit(c.Predict(examples[i])) != bool(examples[i].Label()) {
+			return d.P[i]
 		}
-	}
-	return misclassifications
+		return 0.0
+	})
 }
 
+// Round adds a weak classifier to the model, learned from nexamples
+// examples sampled according to D, or from all of the examples if
+// Reweight is set.
 func (a *AdaBoost) Round(nexamples int) {
-	// Sample from the examples for this round.
-	cumulative := CumulativeDistributionOfDistribution(a.D)
-	var examples []Example
-	for i := 0; i < nexamples; i++ {
-		examples = append(examples, a.Examples[cumulative.Sample(a.rand)])
+	var h Classifier
+	if a.Reweight {
+		h = a.reweightedClassifier()
+	} else {
+		h = a.resampledClassifier(nexamples)
 	}
 
-	h := a.Learner.NewClassifier(examples)
-
 	// Calculate the error of this classifier.
-	e_t := evaluateClassifierWeighted(h, a.Examples, a.D)
-	e_t = math.Max(e_t, math.SmallestNonzeroFloat64)
-	a_t := 0.5 * math.Log((1-e_t)/e_t)
-	for i, example := range a.Examples {
-		a.D.P[i] *= math.Exp(-a_t * float64OfLabel(example.Label()) * h.Predict(example))
+	d := a.D
+	if a.Costs != nil {
+		d = a.costWeighted()
 	}
-	a.D.Normalize()
+	var e_t float64
+	var predicted Bitset
+	if a.Matrix != nil {
+		e_t, predicted = evaluateClassifierOnMatrix(h, a.Matrix, d, a.Parallelism)
+	} else {
+		e_t = evaluateClassifierWeighted(h, a.Examples, d, a.Parallelism)
+	}
+	// Take the logs separately: (1-e_t)/e_t overflows for a perfect
+	// classifier.
+	e_t = math.Max(e_t, math.SmallestNonzeroFloat64)
+	a_t := 0.5 * (math.Log1p(-e_t) - math.Log(e_t))
+	parallelBlocks(len(a.Examples), exampleBlockSize, a.Parallelism, func(block int, start int, end int) {
+		for i := start; i < end; i++ {
+			var prediction float64
+			if predicted != nil {
+				prediction = float64OfLabel(Label(predicted.Has(i)))
+			} else {
+				prediction = h.Predict(a.Examples[i])
+			}
+			a.D.P[i] *= math.Exp(-a_t * float64OfLabel(a.Examples[i].Label()) * prediction)
+			if a.Costs != nil {
+				a.D.P[i] *= a.Costs.of(a.Examples[i].Label())
+			}
+		}
+	})
 	a.H = append(a.H, h)
 	a.A = append(a.A, a_t)
+	a.D.normalize(a.Parallelism)
+	if a.D.Validate() != nil {
+		// After many rounds the weights can all underflow to zero, or
+		// their sum overflow; recover them in log space.
+		a.recomputeDistribution()
+	}
+}
+
+func (a *AdaBoost) reweightedClassifier() Classifier {
+	if learner, ok := a.Learner.(WeightedMatrixLearner); ok && a.Matrix != nil {
+		return learner.NewWeightedClassifierFromMatrix(a.Matrix, a.D.P)
+	}
+	learner, ok := a.Learner.(WeightedLearner)
+	if !ok {
+		panic(fmt.Sprintf("ml: %T can not learn from weighted examples", a.Learner))
+	}
+	return learner.NewWeightedClassifier(a.Examples, a.D.P)
+}
+
+func (a *AdaBoost) resampledClassifier(nexamples int) Classifier {
+	// Sample from the examples for this round.
+	t, err := NewAliasTable(a.D)
+	if err != nil {
+		panic(fmt.Sprintf("ml: can not sample examples: %v", err))
+	}
+	rows := make([]int, nexamples)
+	for i := range rows {
+		rows[i] = t.Sample(a.rand)
+	}
+
+	if learner, ok := a.Learner.(MatrixLearner); ok && a.Matrix != nil {
+		return learner.NewClassifierFromMatrix(a.Matrix.Rows(rows))
+	}
+	examples := make([]Example, nexamples)
+	for i, row := range rows {
+		examples[i] = a.Examples[row]
+	}
+	return a.Learner.NewClassifier(examples)
 }
 
 func (a *AdaBoost) Predict(e Example) float64 {
@@ -2,6 +2,8 @@ package ml
 
 import (
 	"math"
+	"math/rand"
+	"sort"
 )
 
 type FeatureNode struct {
@@ -14,40 +16,181 @@ type LeafNode struct {
 	class bool
 }
 
+type MultiClassFeatureNode struct {
+	feature  Feature
+	positive MultiClassClassifier
+	negative MultiClassClassifier
+}
+
+type MultiClassLeafNode struct {
+	class int
+}
+
 type DecisionTreeBuilder struct {
 	features []Feature
 	maxDepth int
+	// Parallelism is how many goroutines search for the best split.
+	// The trees built do not depend on it.
+	Parallelism int
+	// Numeric are features whose Predict returns a value, or NaN if
+	// the value is missing, to split on at a threshold.
+	Numeric []Feature
+	// Categorical are features to split on with a branch per value.
+	Categorical []CategoricalFeature
+	// GainRatio chooses splits by gain ratio, as C4.5 does, instead
+	// of by information gain. Information gain favors categorical
+	// features with many values.
+	GainRatio bool
+	// FeatureSubset, if positive, is how many of the binary features
+	// to choose from at each split, drawn at random with Rand, as
+	// random forests do.
+	FeatureSubset int
+	Rand          *rand.Rand
+	// Costs, if set, weights the examples of each class by the cost
+	// of misclassifying them, so that splits and leaves minimize the
+	// expected cost rather than the number of errors. Leave it unset
+	// for trees boosted by an AdaBoost with Costs, which already
+	// scales the weights of the examples by their costs.
+	Costs *Costs
 }
 
 func NewDecisionTreeBuilder(fs []Feature, maxDepth int) *DecisionTreeBuilder {
-	return &DecisionTreeBuilder{fs, maxDepth}
+	return &DecisionTreeBuilder{fs, maxDepth, 1, nil, nil, false, 0, nil, nil}
+}
+
+// featureSubset returns the indices of the binary features to
+// consider for a split, in increasing order.
+func (tb *DecisionTreeBuilder) featureSubset(nfeatures int) []int {
+	if tb.FeatureSubset <= 0 || tb.FeatureSubset >= nfeatures {
+		features := make([]int, nfeatures)
+		for i := range features {
+			features[i] = i
+		}
+		return features
+	}
+	features := tb.Rand.Perm(nfeatures)[:tb.FeatureSubset]
+	sort.Ints(features)
+	return features
 }
 
 func (tb *DecisionTreeBuilder) NewClassifier(examples []Example) Classifier {
-	return tb.build(1, examples)
+	return tb.NewClassifierFromMatrix(NewFeatureMatrix(tb.features, examples))
 }
 
-func info(pos int, neg int) float64 {
-	tot := float64(pos + neg)
-	pPos := float64(pos) / tot
-	pNeg := float64(neg) / tot
-	return -pPos*math.Log2(pPos) - pNeg*math.Log2(pNeg)
+// NewWeightedClassifier builds a tree which maximizes the gain in
+// entropy of the weighted class distribution.
+func (tb *DecisionTreeBuilder) NewWeightedClassifier(examples []Example, weights []float64) Classifier {
+	return tb.NewWeightedClassifierFromMatrix(NewFeatureMatrix(tb.features, examples), weights)
 }
 
-type countKey struct {
-	feature bool
-	class   Label
+func (tb *DecisionTreeBuilder) NewWeightedClassifierFromMatrix(m *FeatureMatrix, weights []float64) Classifier {
+	return tb.newClassifier(m, weights)
 }
 
-func (tb *DecisionTreeBuilder) build(depth int, examples []Example) Classifier {
-	npos, nneg := 0, 0
-	for _, example := range examples {
-		if example.Label() {
-			npos += 1
-		} else {
-			nneg += 1
+// NewClassifierFromMatrix builds a tree over all of the examples in
+// m. The builder's features need not be the matrix's features, but
+// it is much faster if they are.
+func (tb *DecisionTreeBuilder) NewClassifierFromMatrix(m *FeatureMatrix) Classifier {
+	return tb.newClassifier(m, nil)
+}
+
+// newClassifier builds a tree over the examples of m with the given
+// weights, or counting each example once if weights is nil.
+func (tb *DecisionTreeBuilder) newClassifier(m *FeatureMatrix, weights []float64) Classifier {
+	if tb.Costs != nil {
+		costWeights := make([]float64, len(m.Examples))
+		for i := range costWeights {
+			costWeights[i] = tb.Costs.of(Label(m.labels.Has(i)))
+			if weights != nil {
+				costWeights[i] *= weights[i]
+			}
+		}
+		weights = costWeights
+	}
+	d := &treeData{m, make([]Bitset, len(tb.features)), make([][]float64, len(tb.Numeric)), make([][]string, len(tb.Categorical)), weights}
+	for i, f := range tb.features {
+		d.columns[i] = m.Column(f)
+	}
+	for i, f := range tb.Numeric {
+		d.numeric[i] = make([]float64, len(m.Examples))
+		for j, e := range m.Examples {
+			d.numeric[i][j] = f.Predict(e)
 		}
 	}
+	for i, f := range tb.Categorical {
+		d.categorical[i] = make([]string, len(m.Examples))
+		for j, e := range m.Examples {
+			d.categorical[i][j] = f.Category(e)
+		}
+	}
+	return tb.build(1, d, fullBitset(len(m.Examples)))
+}
+
+// NewMultiClassClassifier builds a tree which maximizes the gain in
+// entropy of the class distribution. Like the binary builder, it
+// predicts each feature for each example once, and then counts
+// examples with bit operations.
+func (tb *DecisionTreeBuilder) NewMultiClassClassifier(examples []MultiClassExample, nclasses int) MultiClassClassifier {
+	es := make([]Example, len(examples))
+	classes := make([]Bitset, nclasses)
+	for class := range classes {
+		classes[class] = NewBitset(len(examples))
+	}
+	for i, example := range examples {
+		es[i] = example
+		classes[example.Class()].Set(i)
+	}
+	m := NewFeatureMatrix(tb.features, es)
+	columns := make([]Bitset, len(tb.features))
+	for i, f := range tb.features {
+		columns[i] = m.Column(f)
+	}
+	return tb.buildMultiClass(1, columns, classes, fullBitset(len(examples)))
+}
+
+// plogp returns -p*log2(p), taking 0*log2(0) to be 0.
+func plogp(p float64) float64 {
+	if p == 0.0 {
+		return 0.0
+	}
+	return -p * math.Log2(p)
+}
+
+// info returns the entropy, in bits, of a binary class distribution
+// given by counts or weights.
+func info(pos float64, neg float64) float64 {
+	tot := pos + neg
+	return plogp(pos/tot) + plogp(neg/tot)
+}
+
+// entropy returns the entropy, in bits, of the class distribution
+// given by counts.
+func entropy(counts []int) float64 {
+	weights := make([]float64, len(counts))
+	for i, n := range counts {
+		weights[i] = float64(n)
+	}
+	return weightedEntropy(weights)
+}
+
+// weightedEntropy is entropy for a distribution given by weights.
+func weightedEntropy(weights []float64) float64 {
+	tot := 0.0
+	for _, w := range weights {
+		tot += w
+	}
+	h := 0.0
+	for _, w := range weights {
+		h += plogp(w / tot)
+	}
+	return h
+}
+
+func (tb *DecisionTreeBuilder) build(depth int, d *treeData, examples Bitset) Classifier {
+	m, columns := d.m, d.columns
+	n := d.weight(examples)
+	npos := d.andWeight(examples, m.labels)
+	nneg := d.andNotWeight(examples, m.labels)
 
 	// If all examples have the same class, predict that class
 	if npos == 0 {
@@ -67,45 +210,58 @@ func (tb *DecisionTreeBuilder) build(depth int, examples []Example) Classifier {
 	}
 
 	currentInfo := info(npos, nneg)
-	var bestFeature Feature
-	bestGain := 0.0
-
-	// Find feature with best information gain. We don't do normalization
-	// because all of our features are currently binary valued.
-	for _, feature := range tb.features {
-		counts := make(map[countKey]int)
-		for _, example := range examples {
-			f := !math.Signbit(feature.Predict(example))
-			counts[countKey{f, example.Label()}]++
-		}
-		nfeaturePos := counts[countKey{true, true}] + counts[countKey{true, false}]
-		pfeaturePos := float64(nfeaturePos) / float64(len(examples))
-		infoThisFeature := pfeaturePos*info(counts[countKey{true, true}], counts[countKey{true, false}]) + (1.0-pfeaturePos)*info(counts[countKey{false, true}], counts[countKey{false, false}])
-
-		gain := currentInfo - infoThisFeature
-		if gain > bestGain {
-			// fmt.Printf("new best feature: %s, gain: %f\n", feature, gain)
-			bestGain = gain
-			bestFeature = feature
+	featureGain := func(i int) float64 {
+		nfeaturePos := d.andWeight(examples, columns[i])
+		nfeaturePosLabelPos := d.andWeight3(examples, columns[i], m.labels)
+		pfeaturePos := nfeaturePos / n
+		infoThisFeature := pfeaturePos*info(nfeaturePosLabelPos, nfeaturePos-nfeaturePosLabelPos) + (1.0-pfeaturePos)*info(npos-nfeaturePosLabelPos, nneg-(nfeaturePos-nfeaturePosLabelPos))
+		return currentInfo - infoThisFeature
+	}
+
+	// Find feature with best information gain. Choosing by gain ratio
+	// needs the gain of every feature; otherwise only the best one
+	// is a candidate.
+	features := tb.featureSubset(len(columns))
+	var candidates []split
+	if tb.GainRatio {
+		gains := make([]float64, len(features))
+		parallelBlocks(len(features), featureBlockSize, tb.Parallelism, func(block int, start int, end int) {
+			for j := start; j < end; j++ {
+				gains[j] = featureGain(features[j])
+			}
+		})
+		for j, gain := range gains {
+			if gain > 0.0 {
+				nfeaturePos := d.andWeight(examples, columns[features[j]])
+				candidates = append(candidates, split{featureSplit, features[j], 0.0, gain, info(nfeaturePos, n-nfeaturePos)})
+			}
 		}
+	} else if best, gain := findBestFeature(len(features), tb.Parallelism, func(j int) float64 {
+		return featureGain(features[j])
+	}); best != -1 {
+		candidates = append(candidates, split{featureSplit, features[best], 0.0, gain, 0.0})
 	}
+	candidates = append(candidates, tb.thresholdSplits(d, examples, n)...)
+	candidates = append(candidates, tb.categorySplits(d, examples, n)...)
 
-	if bestFeature == nil {
+	best := chooseSplit(candidates, tb.GainRatio)
+	if best == nil {
 		// FIXME: I'm encoding the base rate here; when does this happen?
 		// fmt.Printf("bailing out\n")
 		return &LeafNode{false}
 	}
 
-	// Split examples into positive and negative for this feature.
-	var pos, neg []Example
-	for _, example := range examples {
-		if math.Signbit(bestFeature.Predict(example)) {
-			neg = append(neg, example)
-		} else {
-			pos = append(pos, example)
-		}
+	switch best.kind {
+	case thresholdSplit:
+		return tb.buildThresholdNode(depth, d, examples, best)
+	case categorySplit:
+		return tb.buildCategoryNode(depth, d, examples, best)
 	}
-	return &FeatureNode{bestFeature, tb.build(depth+1, pos), tb.build(depth+1, neg)}
+
+	// Split examples into positive and negative for this feature.
+	pos := examples.And(columns[best.index])
+	neg := examples.AndNot(columns[best.index])
+	return &FeatureNode{tb.features[best.index], tb.build(depth+1, d, pos), tb.build(depth+1, d, neg)}
 }
 
 func (n *LeafNode) Predict(e Example) float64 {
@@ -116,6 +272,19 @@ func (n *LeafNode) Predict(e Example) float64 {
 	}
 }
 
+func (n *LeafNode) predictMatrix(m *FeatureMatrix) Bitset {
+	if n.class {
+		return fullBitset(len(m.Examples))
+	} else {
+		return NewBitset(len(m.Examples))
+	}
+}
+
+func (n *FeatureNode) predictMatrix(m *FeatureMatrix) Bitset {
+	column := m.Column(n.feature)
+	return column.And(predictMatrix(n.positive, m)).Or(predictMatrix(n.negative, m).AndNot(column))
+}
+
 func (n *FeatureNode) Predict(e Example) float64 {
 	if math.Signbit(n.feature.Predict(e)) {
 		return n.negative.Predict(e)
@@ -123,3 +292,73 @@ func (n *FeatureNode) Predict(e Example) float64 {
 		return n.positive.Predict(e)
 	}
 }
+
+// classCounts returns how many of the examples are in each class,
+// given the examples of each class.
+func classCounts(examples Bitset, classes []Bitset) []int {
+	counts := make([]int, len(classes))
+	for class, c := range classes {
+		counts[class] = examples.AndCount(c)
+	}
+	return counts
+}
+
+// majorityClass returns the most common class, preferring the lowest
+// numbered class in a tie.
+func majorityClass(counts []int) int {
+	best := 0
+	for class, n := range counts {
+		if n > counts[best] {
+			best = class
+		}
+	}
+	return best
+}
+
+// buildMultiClass builds a tree over examples, given which examples
+// each feature is positive for and which are in each class.
+func (tb *DecisionTreeBuilder) buildMultiClass(depth int, columns []Bitset, classes []Bitset, examples Bitset) MultiClassClassifier {
+	counts := classCounts(examples, classes)
+	majority := majorityClass(counts)
+	n := examples.Count()
+
+	// If all examples have the same class, or the tree is deep
+	// enough, predict the prevalent class.
+	if counts[majority] == n || depth == tb.maxDepth {
+		return &MultiClassLeafNode{majority}
+	}
+
+	currentInfo := entropy(counts)
+
+	best, _ := findBestFeature(len(columns), tb.Parallelism, func(i int) float64 {
+		posCounts := make([]int, len(classes))
+		negCounts := make([]int, len(classes))
+		for class, c := range classes {
+			posCounts[class] = examples.AndCount3(columns[i], c)
+			negCounts[class] = counts[class] - posCounts[class]
+		}
+		pfeaturePos := float64(examples.AndCount(columns[i])) / float64(n)
+		infoThisFeature := pfeaturePos*entropy(posCounts) + (1.0-pfeaturePos)*entropy(negCounts)
+		return currentInfo - infoThisFeature
+	})
+
+	if best == -1 {
+		return &MultiClassLeafNode{majority}
+	}
+
+	pos := examples.And(columns[best])
+	neg := examples.AndNot(columns[best])
+	return &MultiClassFeatureNode{tb.features[best], tb.buildMultiClass(depth+1, columns, classes, pos), tb.buildMultiClass(depth+1, columns, classes, neg)}
+}
+
+func (n *MultiClassLeafNode) PredictClass(e Example) int {
+	return n.class
+}
+
+func (n *MultiClassFeatureNode) PredictClass(e Example) int {
+	if math.Signbit(n.feature.Predict(e)) {
+		return n.negative.PredictClass(e)
+	} else {
+		return n.positive.PredictClass(e)
+	}
+}
@@ -0,0 +1,329 @@
+package ml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// A Calibrator maps a classifier's score to the probability that the
+// example is positive.
+type Calibrator interface {
+	Probability(score float64) float64
+}
+
+// CalibratedClassifier wraps a classifier whose scores, like
+// AdaBoost's margins, are only meaningful for their sign and order,
+// and turns them into probabilities.
+type CalibratedClassifier struct {
+	Classifier Classifier
+	Calibrator Calibrator
+}
+
+// minProbability bounds the probabilities a CalibratedClassifier
+// predicts away from 0 and 1, so that its log odds are finite.
+const minProbability = 1e-6
+
+// Probability returns the probability that e is positive.
+func (c *CalibratedClassifier) Probability(e Example) float64 {
+	return c.Calibrator.Probability(c.Classifier.Predict(e))
+}
+
+// Predict returns the log odds that e is positive.
+func (c *CalibratedClassifier) Predict(e Example) float64 {
+	p := math.Min(math.Max(c.Probability(e), minProbability), 1.0-minProbability)
+	return math.Log(p / (1.0 - p))
+}
+
+// probability returns the probability c gives that e is positive:
+// calibrated if c is a CalibratedClassifier, and otherwise the
+// logistic function of its score, as Evaluate's log loss assumes.
+func probability(c Classifier, e Example) float64 {
+	if c, ok := c.(*CalibratedClassifier); ok {
+		return c.Probability(e)
+	}
+	return sigmoid(c.Predict(e))
+}
+
+// scoreExamples returns c's scores for the examples, sorted in
+// increasing order.
+func scoreExamples(c Classifier, examples []Example) []scoredLabel {
+	scored := make([]scoredLabel, len(examples))
+	for i, e := range examples {
+		scored[i] = scoredLabel{c.Predict(e), e.Label()}
+	}
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].score < scored[j].score
+	})
+	return scored
+}
+
+// PlattCalibrator fits the logistic function of a linear function of
+// the score, sigmoid(A*score + B), as in Platt, "Probabilistic
+// Outputs for Support Vector Machines", 1999. Two parameters can not
+// overfit a small held out set, but the scores must be monotonic in
+// the log odds.
+type PlattCalibrator struct {
+	A float64 `json:"a"`
+	B float64 `json:"b"`
+}
+
+func (p *PlattCalibrator) Probability(score float64) float64 {
+	return sigmoid(p.A*score + p.B)
+}
+
+// softplus returns log(1 + exp(z)) without overflow.
+func softplus(z float64) float64 {
+	if z > 0 {
+		return z + math.Log1p(math.Exp(-z))
+	}
+	return math.Log1p(math.Exp(z))
+}
+
+// FitPlatt fits a PlattCalibrator to c's scores for examples, which
+// should not be the ones c was trained on. It uses Newton's method
+// with backtracking from Lin et al., "A Note on Platt's Probabilistic
+// Outputs for Support Vector Machines", 2007. Like Platt, it fits
+// targets slightly short of 0 and 1 so that separable scores do not
+// make A infinite.
+func FitPlatt(c Classifier, examples []Example) *PlattCalibrator {
+	scored := scoreExamples(c, examples)
+	npos, nneg := 0.0, 0.0
+	for _, s := range scored {
+		if s.label {
+			npos++
+		} else {
+			nneg++
+		}
+	}
+	hiTarget, loTarget := (npos+1.0)/(npos+2.0), 1.0/(nneg+2.0)
+	targets := make([]float64, len(scored))
+	for i, s := range scored {
+		if s.label {
+			targets[i] = hiTarget
+		} else {
+			targets[i] = loTarget
+		}
+	}
+	objective := func(a, b float64) float64 {
+		f := 0.0
+		for i, s := range scored {
+			z := a*s.score + b
+			f += softplus(z) - targets[i]*z
+		}
+		return f
+	}
+
+	const (
+		maxIterations = 100
+		minStep       = 1e-10
+		// sigma keeps the Hessian positive definite.
+		sigma   = 1e-12
+		epsilon = 1e-5
+	)
+	p := &PlattCalibrator{0.0, math.Log((npos + 1.0) / (nneg + 1.0))}
+	f := objective(p.A, p.B)
+	for iteration := 0; iteration < maxIterations; iteration++ {
+		h11, h22, h21, g1, g2 := sigma, sigma, 0.0, 0.0, 0.0
+		for i, s := range scored {
+			q := p.Probability(s.score)
+			d1 := q - targets[i]
+			d2 := q * (1.0 - q)
+			h11 += s.score * s.score * d2
+			h22 += d2
+			h21 += s.score * d2
+			g1 += s.score * d1
+			g2 += d1
+		}
+		if math.Abs(g1) < epsilon && math.Abs(g2) < epsilon {
+			break
+		}
+
+		det := h11*h22 - h21*h21
+		dA := -(h22*g1 - h21*g2) / det
+		dB := -(-h21*g1 + h11*g2) / det
+		gd := g1*dA + g2*dB
+		step := 1.0
+		for ; step >= minStep; step /= 2.0 {
+			a, b := p.A+step*dA, p.B+step*dB
+			if newF := objective(a, b); newF < f+1e-4*step*gd {
+				p.A, p.B, f = a, b, newF
+				break
+			}
+		}
+		if step < minStep {
+			break
+		}
+	}
+	return p
+}
+
+// IsotonicCalibrator fits a non-decreasing function of the score by
+// isotonic regression. It makes no assumption about the shape of the
+// function, but needs more held out examples than Platt scaling not
+// to overfit. Between Scores, the probability is interpolated
+// linearly; beyond them, it is that of the nearest score.
+type IsotonicCalibrator struct {
+	Scores        []float64 `json:"scores"`
+	Probabilities []float64 `json:"probabilities"`
+}
+
+func (c *IsotonicCalibrator) Probability(score float64) float64 {
+	n := len(c.Scores)
+	if n == 0 {
+		return 0.5
+	}
+	i := sort.SearchFloat64s(c.Scores, score)
+	if i == 0 {
+		return c.Probabilities[0]
+	} else if i == n {
+		return c.Probabilities[n-1]
+	}
+	x0, x1 := c.Scores[i-1], c.Scores[i]
+	p0, p1 := c.Probabilities[i-1], c.Probabilities[i]
+	return p0 + (p1-p0)*(score-x0)/(x1-x0)
+}
+
+// isotonicBlock is a run of examples, in order of score, which the
+// fitted function gives the same probability.
+type isotonicBlock struct {
+	lo, hi    float64
+	positives float64
+	n         float64
+}
+
+func (b *isotonicBlock) mean() float64 {
+	return b.positives / b.n
+}
+
+// FitIsotonic fits an IsotonicCalibrator to c's scores for examples,
+// which should not be the ones c was trained on, with the pool
+// adjacent violators algorithm.
+func FitIsotonic(c Classifier, examples []Example) *IsotonicCalibrator {
+	scored := scoreExamples(c, examples)
+	var blocks []isotonicBlock
+	for i := 0; i < len(scored); {
+		// Examples with the same score must get the same
+		// probability, so they start out in one block.
+		b := isotonicBlock{scored[i].score, scored[i].score, 0.0, 0.0}
+		for ; i < len(scored) && scored[i].score == b.lo; i++ {
+			if scored[i].label {
+				b.positives++
+			}
+			b.n++
+		}
+		blocks = append(blocks, b)
+		for len(blocks) > 1 {
+			last, prev := &blocks[len(blocks)-1], &blocks[len(blocks)-2]
+			if prev.mean() < last.mean() {
+				break
+			}
+			prev.hi = last.hi
+			prev.positives += last.positives
+			prev.n += last.n
+			blocks = blocks[:len(blocks)-1]
+		}
+	}
+
+	ic := &IsotonicCalibrator{}
+	for _, b := range blocks {
+		ic.Scores = append(ic.Scores, b.lo)
+		ic.Probabilities = append(ic.Probabilities, b.mean())
+		if b.hi != b.lo {
+			ic.Scores = append(ic.Scores, b.hi)
+			ic.Probabilities = append(ic.Probabilities, b.mean())
+		}
+	}
+	return ic
+}
+
+// ReliabilityBin summarizes the examples whose predicted probability
+// is in [Lower, Upper).
+type ReliabilityBin struct {
+	Lower     float64 `json:"lower"`
+	Upper     float64 `json:"upper"`
+	Examples  int     `json:"examples"`
+	Predicted float64 `json:"predicted"`
+	// Observed is the fraction of the examples which are positive.
+	// A well calibrated classifier's is close to Predicted.
+	Observed float64 `json:"observed"`
+}
+
+// Reliability is a reliability diagram: the fraction of examples
+// which are positive, against the probability predicted for them.
+type Reliability struct {
+	Bins []ReliabilityBin `json:"bins"`
+	// ExpectedCalibrationError is the mean difference between the
+	// predicted and observed probability of the bins, weighted by
+	// their number of examples.
+	ExpectedCalibrationError float64 `json:"ece"`
+	// BrierScore is the mean squared difference between the
+	// predicted probability and the label.
+	BrierScore float64 `json:"brier"`
+}
+
+// ReliabilityDiagram bins the examples into nbins equal ranges of the
+// probability c predicts for them. Classifiers other than
+// CalibratedClassifiers are treated as predicting the logistic
+// function of their score, so that the diagram shows how well they
+// are calibrated before and after calibration.
+func ReliabilityDiagram(c Classifier, examples []Example, nbins int) *Reliability {
+	r := &Reliability{}
+	for i := 0; i < nbins; i++ {
+		r.Bins = append(r.Bins, ReliabilityBin{float64(i) / float64(nbins), float64(i+1) / float64(nbins), 0, 0.0, 0.0})
+	}
+	for _, e := range examples {
+		p := probability(c, e)
+		b := &r.Bins[int(math.Min(p*float64(nbins), float64(nbins-1)))]
+		b.Examples++
+		b.Predicted += p
+		y := 0.0
+		if e.Label() {
+			y = 1.0
+			b.Observed++
+		}
+		r.BrierScore += (p - y) * (p - y)
+	}
+	for i := range r.Bins {
+		b := &r.Bins[i]
+		if b.Examples == 0 {
+			continue
+		}
+		b.Predicted /= float64(b.Examples)
+		b.Observed /= float64(b.Examples)
+		r.ExpectedCalibrationError += float64(b.Examples) * math.Abs(b.Predicted-b.Observed)
+	}
+	r.ExpectedCalibrationError = ratio(r.ExpectedCalibrationError, float64(len(examples)))
+	r.BrierScore = ratio(r.BrierScore, float64(len(examples)))
+	return r
+}
+
+// String returns the diagram as a table, with a bar for the observed
+// fraction of positives in each bin.
+func (r *Reliability) String() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "ece=%f brier=%f\n", r.ExpectedCalibrationError, r.BrierScore)
+	fmt.Fprintf(&buf, "  bin          examples  predicted  observed")
+	for _, b := range r.Bins {
+		fmt.Fprintf(&buf, "\n  [%.2f,%.2f)  %8d  %9.4f  %8.4f", b.Lower, b.Upper, b.Examples, b.Predicted, b.Observed)
+		if bar := bar(b.Observed, 20); bar != "" {
+			buf.WriteString("  " + bar)
+		}
+	}
+	return buf.String()
+}
+
+// bar draws a fraction from 0 to 1 as a bar width characters wide at
+// most.
+func bar(fraction float64, width int) string {
+	n := int(math.Round(fraction * float64(width)))
+	return string(bytes.Repeat([]byte("#"), n))
+}
+
+// WriteJSON writes the diagram as JSON.
+func (r *Reliability) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(r)
+}
@@ -0,0 +1,145 @@
+package ml
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// logisticScores returns examples whose scores are spread evenly and
+// whose log odds of being positive are a*score + b.
+func logisticScores(r *rand.Rand, n int, a float64, b float64) []Example {
+	var dataset []Example
+	for i := 0; i < n; i++ {
+		score := -3.0 + 6.0*r.Float64()
+		dataset = append(dataset, &scoredDatum{score, Label(r.Float64() < sigmoid(a*score+b))})
+	}
+	return dataset
+}
+
+func TestFitPlatt(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	dataset := logisticScores(r, 5000, 2.0, -1.0)
+	p := FitPlatt(scoreClassifier{}, dataset)
+	if math.Abs(p.A-2.0) > 0.2 || math.Abs(p.B+1.0) > 0.2 {
+		t.Errorf("expected A=2 and B=-1 but was A=%f and B=%f", p.A, p.B)
+	}
+}
+
+func TestFitPlattSeparable(t *testing.T) {
+	dataset := []Example{
+		&scoredDatum{-2.0, false},
+		&scoredDatum{-1.0, false},
+		&scoredDatum{1.0, true},
+		&scoredDatum{2.0, true},
+	}
+	p := FitPlatt(scoreClassifier{}, dataset)
+	if math.IsInf(p.A, 0) || math.IsNaN(p.A) || p.A <= 0.0 {
+		t.Errorf("expected a finite positive slope but was %f", p.A)
+	}
+	if q := p.Probability(2.0); q > 0.99 {
+		t.Errorf("expected the probability to stay short of 1 but was %f", q)
+	}
+}
+
+func TestFitIsotonic(t *testing.T) {
+	dataset := []Example{
+		&scoredDatum{-2.0, false},
+		&scoredDatum{-1.0, true},
+		&scoredDatum{0.0, false},
+		&scoredDatum{1.0, true},
+		&scoredDatum{1.0, false},
+		&scoredDatum{2.0, true},
+	}
+	ic := FitIsotonic(scoreClassifier{}, dataset)
+	for _, c := range []struct {
+		score       float64
+		probability float64
+	}{
+		{-3.0, 0.0},
+		{-2.0, 0.0},
+		{-1.0, 0.5},
+		{-0.5, 0.5},
+		{0.0, 0.5},
+		{0.5, 0.5},
+		{1.0, 0.5},
+		{1.5, 0.75},
+		{2.0, 1.0},
+		{3.0, 1.0},
+	} {
+		if p := ic.Probability(c.score); math.Abs(p-c.probability) > 1e-12 {
+			t.Errorf("expected probability %f for score %f but was %f", c.probability, c.score, p)
+		}
+	}
+}
+
+func TestReliabilityDiagram(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	// Scores which are twice the log odds make the logistic function
+	// of the score overconfident.
+	train := logisticScores(r, 5000, 0.5, 0.0)
+	test := logisticScores(r, 5000, 0.5, 0.0)
+
+	before := ReliabilityDiagram(scoreClassifier{}, test, 10)
+	calibrated := &CalibratedClassifier{scoreClassifier{}, FitIsotonic(scoreClassifier{}, train)}
+	after := ReliabilityDiagram(calibrated, test, 10)
+	if after.ExpectedCalibrationError > 0.03 {
+		t.Errorf("expected the calibrated classifier to be well calibrated but ECE was %f", after.ExpectedCalibrationError)
+	}
+	if before.ExpectedCalibrationError < 2*after.ExpectedCalibrationError {
+		t.Errorf("expected calibration to reduce ECE %f but was %f", before.ExpectedCalibrationError, after.ExpectedCalibrationError)
+	}
+	if after.BrierScore >= before.BrierScore {
+		t.Errorf("expected calibration to reduce Brier score %f but was %f", before.BrierScore, after.BrierScore)
+	}
+	n := 0
+	for _, b := range after.Bins {
+		n += b.Examples
+	}
+	if n != len(test) {
+		t.Errorf("expected %d examples in the bins but was %d", len(test), n)
+	}
+}
+
+func TestSaveAndLoadCalibratedClassifier(t *testing.T) {
+	dataset := []Example{
+		&datum{"red", "heavy", true},
+		&datum{"red", "light", false},
+		&datum{"yellow", "light", false},
+		&datum{"yellow", "heavy", true},
+		&datum{"yellow", "light", true},
+	}
+	features := []Feature{
+		&reflectedFeature{"Color", "red"},
+		&reflectedFeature{"Weight", "heavy"},
+	}
+	booster := NewAdaBoost(dataset, NewDecisionTreeBuilder(features, 3), rand.New(rand.NewSource(42)))
+	for i := 0; i < 3; i++ {
+		booster.Round(len(dataset))
+	}
+
+	for _, calibrator := range []Calibrator{FitPlatt(booster, dataset), FitIsotonic(booster, dataset)} {
+		calibrated := &CalibratedClassifier{booster, calibrator}
+		var buf bytes.Buffer
+		if err := SaveModel(&buf, calibrated); err != nil {
+			t.Fatalf("should have saved the model: %v", err)
+		}
+		loaded, err := LoadModel(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("should have loaded the model: %v", err)
+		}
+		for _, example := range dataset {
+			if expected, actual := calibrated.Predict(example), loaded.Predict(example); expected != actual {
+				t.Errorf("expected loaded %T to predict %f for %v but was %f", calibrator, expected, example, actual)
+			}
+		}
+		a, err := LoadAdaBoost(&buf)
+		if err != nil {
+			t.Fatalf("should have loaded the calibrated AdaBoost: %v", err)
+		}
+		if len(a.H) != len(booster.H) {
+			t.Errorf("expected %d classifiers but was %d", len(booster.H), len(a.H))
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package ml
+
+import (
+	"math"
+)
+
+// Costs are the costs of each kind of misclassification, relative to
+// each other. Missing a rare label is usually worse than suggesting
+// it wrongly.
+type Costs struct {
+	FalsePositive float64
+	FalseNegative float64
+}
+
+// of returns the cost of misclassifying an example with label l.
+func (c *Costs) of(l Label) float64 {
+	if l {
+		return c.FalseNegative
+	}
+	return c.FalsePositive
+}
+
+// PriorDistribution returns a distribution over the examples which
+// gives the positive examples total weight prior, and the negative
+// examples the rest, weighting examples of the same class equally.
+// A prior of 0.5 balances the classes. If the examples are all of one
+// class, it is uniform.
+func PriorDistribution(es []Example, prior float64) *Distribution {
+	npos := 0
+	for _, e := range es {
+		if e.Label() {
+			npos++
+		}
+	}
+	if npos == 0 || npos == len(es) {
+		return UniformDistribution(len(es))
+	}
+	d := &Distribution{make([]float64, len(es))}
+	for i, e := range es {
+		if e.Label() {
+			d.P[i] = prior / float64(npos)
+		} else {
+			d.P[i] = (1.0 - prior) / float64(len(es)-npos)
+		}
+	}
+	return d
+}
+
+// SetInitialDistribution starts boosting from d instead of from the
+// uniform distribution. Call it before the first round, or before
+// Resume.
+func (a *AdaBoost) SetInitialDistribution(d *Distribution) {
+	a.Initial = d
+	a.D = &Distribution{append([]float64(nil), d.P...)}
+}
+
+// costWeighted returns D scaled by the cost of misclassifying each
+// example, normalized. AdaBoost's error is measured against it.
+func (a *AdaBoost) costWeighted() *Distribution {
+	d := &Distribution{make([]float64, len(a.D.P))}
+	parallelBlocks(len(d.P), exampleBlockSize, a.Parallelism, func(block int, start int, end int) {
+		for i := start; i < end; i++ {
+			d.P[i] = a.D.P[i] * a.Costs.of(a.Examples[i].Label())
+		}
+	})
+	d.normalize(a.Parallelism)
+	return d
+}
+
+// logCost returns the log of the factor by which each round scales
+// the weight of example i.
+func (a *AdaBoost) logCost(i int) float64 {
+	if a.Costs == nil {
+		return 0.0
+	}
+	return math.Log(a.Costs.of(a.Examples[i].Label()))
+}
@@ -0,0 +1,94 @@
+package ml
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestPriorDistribution(t *testing.T) {
+	dataset := []Example{
+		&datum{"red", "heavy", true},
+		&datum{"red", "light", false},
+		&datum{"yellow", "light", false},
+		&datum{"yellow", "heavy", false},
+	}
+	d := PriorDistribution(dataset, 0.5)
+	expected := []float64{0.5, 0.5 / 3.0, 0.5 / 3.0, 0.5 / 3.0}
+	for i, p := range d.P {
+		if math.Abs(p-expected[i]) > 1e-12 {
+			t.Errorf("expected example %d to have weight %f but was %f", i, expected[i], p)
+		}
+	}
+}
+
+func TestCostSensitiveDecisionTree(t *testing.T) {
+	// A tree of depth 1 is a leaf which predicts the majority class,
+	// unless the cost of missing the minority is high enough.
+	dataset := []Example{
+		&datum{"red", "heavy", true},
+		&datum{"red", "heavy", false},
+		&datum{"red", "heavy", false},
+	}
+	features := []Feature{&reflectedFeature{"Color", "red"}}
+	tb := NewDecisionTreeBuilder(features, 1)
+	if p := tb.NewClassifier(dataset).Predict(dataset[0]); p > 0.0 {
+		t.Errorf("expected the majority class to be predicted but was %f", p)
+	}
+	tb.Costs = &Costs{1.0, 3.0}
+	if p := tb.NewClassifier(dataset).Predict(dataset[0]); p < 0.0 {
+		t.Errorf("expected the costly class to be predicted but was %f", p)
+	}
+}
+
+func TestCostSensitiveAdaBoost(t *testing.T) {
+	// Positive examples are rare, and bit 0 is only a noisy sign of
+	// them.
+	r := rand.New(rand.NewSource(19))
+	var features []Feature
+	for i := 0; i < 4; i++ {
+		features = append(features, bitFeature(i))
+	}
+	var dataset []Example
+	for i := 0; i < 1000; i++ {
+		d := &bitsDatum{make([]bool, len(features)), false}
+		d.class = Label(r.Intn(10) == 0)
+		for j := range d.bits {
+			d.bits[j] = r.Intn(2) == 0
+		}
+		d.bits[0] = bool(d.class) != (r.Intn(5) == 0)
+		dataset = append(dataset, d)
+	}
+
+	recall := func(a *AdaBoost) float64 {
+		return Evaluate(a, dataset).Recall
+	}
+	plain := NewAdaBoost(dataset, NewDecisionTreeBuilder(features, 2), rand.New(rand.NewSource(42)))
+	costly := NewAdaBoost(dataset, NewDecisionTreeBuilder(features, 2), rand.New(rand.NewSource(42)))
+	costly.Costs = &Costs{1.0, 5.0}
+	balanced := NewAdaBoost(dataset, NewDecisionTreeBuilder(features, 2), rand.New(rand.NewSource(42)))
+	balanced.SetInitialDistribution(PriorDistribution(dataset, 0.5))
+	for i := 0; i < 5; i++ {
+		plain.Round(500)
+		costly.Round(500)
+		balanced.Round(500)
+	}
+	if recall(plain) > 0.5 {
+		t.Errorf("expected symmetric boosting to miss most positive examples but recall was %f", recall(plain))
+	}
+	if recall(costly) < 0.7 {
+		t.Errorf("expected cost-sensitive boosting to find most positive examples but recall was %f", recall(costly))
+	}
+	if recall(balanced) < 0.7 {
+		t.Errorf("expected balanced boosting to find most positive examples but recall was %f", recall(balanced))
+	}
+
+	// The distribution can be recovered from the model.
+	p := append([]float64(nil), costly.D.P...)
+	costly.recomputeDistribution()
+	for i := range p {
+		if math.Abs(p[i]-costly.D.P[i]) > 1e-9 {
+			t.Fatalf("expected recomputed weight of example %d to be %g but was %g", i, p[i], costly.D.P[i])
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package ml
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// A LearnerFactory makes a learner for one fold of cross-validation.
+// It is given the fold's training examples so that it can, for
+// example, extract features from them without looking at the held
+// out examples.
+type LearnerFactory func(train []Example) Learner
+
+// Folds are the indices of the held out examples of each fold.
+type Folds [][]int
+
+// checkFolds panics unless k is at least 2: with one fold there is
+// nothing to train on.
+func checkFolds(k int) {
+	if k < 2 {
+		panic(fmt.Sprintf("ml: can not cross-validate with %d folds; there must be at least 2", k))
+	}
+}
+
+// KFold splits n examples into k folds of nearly equal size at
+// random. k must be at least 2.
+func KFold(n int, k int, r *rand.Rand) Folds {
+	checkFolds(k)
+	folds := make(Folds, k)
+	for i, j := range r.Perm(n) {
+		folds[i%k] = append(folds[i%k], j)
+	}
+	for _, fold := range folds {
+		sort.Ints(fold)
+	}
+	return folds
+}
+
+// StratifiedKFold splits examples into k folds at random, keeping the
+// proportion of positive examples in each fold as close as possible
+// to the proportion overall. k must be at least 2.
+func StratifiedKFold(es []Example, k int, r *rand.Rand) Folds {
+	checkFolds(k)
+	folds := make(Folds, k)
+	next := 0
+	for _, class := range []Label{true, false} {
+		var indices []int
+		for i, e := range es {
+			if e.Label() == class {
+				indices = append(indices, i)
+			}
+		}
+		// Carry on dealing from the fold after the last positive
+		// example so that the fold sizes stay balanced.
+		for _, j := range r.Perm(len(indices)) {
+			folds[next] = append(folds[next], indices[j])
+			next = (next + 1) % k
+		}
+	}
+	for _, fold := range folds {
+		sort.Ints(fold)
+	}
+	return folds
+}
+
+// LeaveOneGroupOut makes one fold per group, holding out all of the
+// examples in that group. Folds are in alphabetical order of group.
+func LeaveOneGroupOut(es []Example, group func(Example) string) Folds {
+	groups := make(map[string][]int)
+	for i, e := range es {
+		g := group(e)
+		groups[g] = append(groups[g], i)
+	}
+	var names []string
+	for g := range groups {
+		names = append(names, g)
+	}
+	sort.Strings(names)
+	folds := make(Folds, len(names))
+	for i, g := range names {
+		folds[i] = groups[g]
+	}
+	return folds
+}
+
+// MetricSummary is the mean and sample variance of a metric over the
+// folds of cross-validation.
+type MetricSummary struct {
+	Mean     float64 `json:"mean"`
+	Variance float64 `json:"variance"`
+}
+
+type CrossValidation struct {
+	// Evaluations has the evaluation of each fold's held out
+	// examples.
+	Evaluations []*Evaluation            `json:"folds"`
+	Metrics     map[string]MetricSummary `json:"metrics"`
+}
+
+// CrossValidate trains a classifier for each fold on the examples
+// outside the fold and evaluates it on the examples in the fold.
+func CrossValidate(factory LearnerFactory, es []Example, folds Folds) *CrossValidation {
+	cv := &CrossValidation{nil, make(map[string]MetricSummary)}
+	for _, fold := range folds {
+		heldOut := make([]bool, len(es))
+		for _, i := range fold {
+			heldOut[i] = true
+		}
+		var train, test []Example
+		for i, e := range es {
+			if heldOut[i] {
+				test = append(test, e)
+			} else {
+				train = append(train, e)
+			}
+		}
+		c := factory(train).NewClassifier(train)
+		cv.Evaluations = append(cv.Evaluations, Evaluate(c, test))
+	}
+
+	for _, name := range EvaluationMetrics {
+		var values []float64
+		for _, ev := range cv.Evaluations {
+			values = append(values, ev.Metric(name))
+		}
+		cv.Metrics[name] = summarize(values)
+	}
+	return cv
+}
+
+func summarize(values []float64) MetricSummary {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if len(values) < 2 {
+		return MetricSummary{mean, 0.0}
+	}
+	squares := 0.0
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return MetricSummary{mean, squares / float64(len(values)-1)}
+}
+
+// String returns the mean and standard deviation of each metric.
+func (cv *CrossValidation) String() string {
+	var metrics []string
+	for _, name := range EvaluationMetrics {
+		m := cv.Metrics[name]
+		metrics = append(metrics, fmt.Sprintf("%s=%f±%f", name, m.Mean, math.Sqrt(m.Variance)))
+	}
+	return fmt.Sprintf("%d folds: %s", len(cv.Evaluations), strings.Join(metrics, " "))
+}
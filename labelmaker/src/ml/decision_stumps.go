@@ -16,6 +16,13 @@ func NewDecisionStumper(fs []Feature, es []Example, r *rand.Rand) *DecisionStump
 }
 
 func (stumper *DecisionStumper) NewClassifier(examples []Example) Classifier {
+	return stumper.NewWeightedClassifier(examples, UniformDistribution(len(examples)).P)
+}
+
+// NewWeightedClassifier chooses the stump with the least weighted
+// error.
+func (stumper *DecisionStumper) NewWeightedClassifier(examples []Example, weights []float64) Classifier {
+	d := &Distribution{weights}
 	var bestStump Feature = nil
 	bestError := 1.0
 
@@ -25,7 +32,7 @@ func (stumper *DecisionStumper) NewClassifier(examples []Example) Classifier {
 		f2 := stumper.features[stumper.r.Intn(len(stumper.features))]
 		var feature Feature = &andFeature{f1, f2}
 
-		error := evaluateClassifier(feature, examples)
+		error := evaluateClassifierWeighted(feature, examples, d, 1)
 
 		if error > 0.5 {
 			feature = &FeatureNegater{feature}
@@ -1,7 +1,10 @@
 package ml
 
 import (
+	"fmt"
+	"math"
 	"math/rand"
+	"sort"
 )
 
 type Distribution struct {
@@ -22,13 +25,20 @@ func UniformDistribution(nitems int) *Distribution {
 }
 
 func (d *Distribution) Normalize() {
-	sum := 0.0
-	for _, v := range d.P {
-		sum += v
-	}
-	for i, _ := range d.P {
-		d.P[i] /= sum
-	}
+	d.normalize(1)
+}
+
+// normalize is Normalize using up to parallelism goroutines. The
+// result does not depend on parallelism.
+func (d *Distribution) normalize(parallelism int) {
+	sum := parallelSum(len(d.P), parallelism, func(i int) float64 {
+		return d.P[i]
+	})
+	parallelBlocks(len(d.P), exampleBlockSize, parallelism, func(block int, start int, end int) {
+		for i := start; i < end; i++ {
+			d.P[i] /= sum
+		}
+	})
 }
 
 func CumulativeDistributionOfDistribution(dist *Distribution) *CumulativeDistribution {
@@ -42,26 +52,209 @@ func CumulativeDistributionOfDistribution(dist *Distribution) *CumulativeDistrib
 }
 
 // Sample draws a sample, weighted by a distribution, and returns the
-// index of the sample.
+// index of the sample: the first whose cumulative weight is at least
+// a uniform draw. If rounding leaves the total weight short of the
+// draw, it returns the last index with any weight.
 func (dist *CumulativeDistribution) Sample(r *rand.Rand) int {
-	return search(r.Float64(), dist.P, 0, len(dist.P))
+	s := r.Float64()
+	i := sort.Search(len(dist.P), func(i int) bool {
+		return s <= dist.P[i]
+	})
+	if i == len(dist.P) {
+		for i = len(dist.P) - 1; i > 0 && dist.P[i] == dist.P[i-1]; i-- {
+		}
+	}
+	return i
+}
+
+// Validate returns an error unless the distribution can be sampled
+// from: every weight must be a finite, non-negative number, and at
+// least one must be positive. The weights need not sum to 1.
+func (d *Distribution) Validate() error {
+	if len(d.P) == 0 {
+		return fmt.Errorf("Distribution is empty")
+	}
+	sum := 0.0
+	for i, p := range d.P {
+		switch {
+		case math.IsNaN(p):
+			return fmt.Errorf("Weight %d is NaN", i)
+		case math.IsInf(p, 0):
+			return fmt.Errorf("Weight %d is infinite", i)
+		case p < 0.0:
+			return fmt.Errorf("Weight %d is negative (%g)", i, p)
+		}
+		sum += p
+	}
+	if sum == 0.0 {
+		return fmt.Errorf("Distribution has no positive weights")
+	}
+	if math.IsInf(sum, 0) {
+		return fmt.Errorf("Distribution's weights overflow")
+	}
+	return nil
+}
+
+// AliasTable samples from a distribution in constant time, after
+// linear time to build it, by Walker's alias method as described in
+// Vose, "A Linear Algorithm for Generating Random Numbers with a
+// Given Distribution", 1991. Each draw picks a column uniformly, then
+// either the column's own item or its alias.
+type AliasTable struct {
+	prob  []float64
+	alias []int
+}
+
+// NewAliasTable builds an alias table for d, which it validates.
+func NewAliasTable(d *Distribution) (*AliasTable, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	n := len(d.P)
+	sum := 0.0
+	for _, p := range d.P {
+		sum += p
+	}
+	t := &AliasTable{make([]float64, n), make([]int, n)}
+	scaled := make([]float64, n)
+	var small, large []int
+	for i, p := range d.P {
+		scaled[i] = p * float64(n) / sum
+		if scaled[i] < 1.0 {
+			small = append(small, i)
+		} else {
+			large = append(large, i)
+		}
+	}
+	for len(small) > 0 && len(large) > 0 {
+		s, l := small[len(small)-1], large[len(large)-1]
+		small = small[:len(small)-1]
+		t.prob[s], t.alias[s] = scaled[s], l
+		scaled[l] -= 1.0 - scaled[s]
+		if scaled[l] < 1.0 {
+			large = large[:len(large)-1]
+			small = append(small, l)
+		}
+	}
+	// What is left over is 1 but for rounding error.
+	for _, i := range append(small, large...) {
+		t.prob[i], t.alias[i] = 1.0, i
+	}
+	return t, nil
+}
+
+// Sample returns the index of an item drawn from the distribution.
+func (t *AliasTable) Sample(r *rand.Rand) int {
+	i := r.Intn(len(t.prob))
+	if r.Float64() < t.prob[i] {
+		return i
+	}
+	return t.alias[i]
+}
+
+// SampleWithoutReplacement draws k distinct indices, each draw
+// weighted by the distribution over the indices not yet drawn, with
+// the method of Efraimidis and Spirakis, "Weighted Random Sampling
+// with a Reservoir", 2006. Indices with no weight are never drawn, so
+// there must be at least k with weight. The indices are in the order
+// they were drawn.
+func (d *Distribution) SampleWithoutReplacement(k int, r *rand.Rand) ([]int, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	type keyed struct {
+		index int
+		key   float64
+	}
+	var keys []keyed
+	for i, p := range d.P {
+		if p > 0.0 {
+			// log(u)/p orders the same as u^(1/p) without underflow.
+			keys = append(keys, keyed{i, math.Log(r.Float64()) / p})
+		}
+	}
+	if k < 0 {
+		return nil, fmt.Errorf("Can not draw %d items", k)
+	}
+	if k > len(keys) {
+		return nil, fmt.Errorf("Can not draw %d items without replacement from %d with weight", k, len(keys))
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i].key > keys[j].key
+	})
+	indices := make([]int, k)
+	for i := range indices {
+		indices[i] = keys[i].index
+	}
+	return indices, nil
 }
 
-// search does a binary search to find the index i such that:
-// C_i-1 < sample <= C_i
-func search(s float64, cumulative []float64, startInclusive int, endExclusive int) int {
-	if startInclusive == endExclusive {
-		if !((s <= cumulative[0] && startInclusive == 0) || cumulative[startInclusive-1] < s) {
-			panic("Search did not find a valid index. Is the cumulative distribution valid?")
+// StratifiedSample draws n indices with replacement, weighted by the
+// distribution, but draws from each stratum exactly its share of n
+// by weight, rounded by largest remainder, rather than leaving it to
+// chance. strata gives the stratum of each index; with the label of
+// each example, a rare class is as well represented in every sample
+// as its weight says. Indices are grouped by stratum, in order of
+// stratum.
+func (d *Distribution) StratifiedSample(strata []int, n int, r *rand.Rand) ([]int, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	if len(strata) != len(d.P) {
+		return nil, fmt.Errorf("Distribution has %d weights but %d strata", len(d.P), len(strata))
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("Can not draw %d items", n)
+	}
+	members := make(map[int][]int)
+	weights := make(map[int]float64)
+	var names []int
+	total := 0.0
+	for i, s := range strata {
+		if _, ok := members[s]; !ok {
+			names = append(names, s)
 		}
-		return startInclusive
+		members[s] = append(members[s], i)
+		weights[s] += d.P[i]
+		total += d.P[i]
 	}
-	mid := startInclusive + (endExclusive-startInclusive)/2
-	if s <= cumulative[mid] {
-		return search(s, cumulative, startInclusive, mid)
+	sort.Ints(names)
+
+	counts := make([]int, len(names))
+	remainders := make([]int, len(names))
+	allocated := 0
+	for j, s := range names {
+		share := float64(n) * weights[s] / total
+		counts[j] = int(math.Floor(share))
+		allocated += counts[j]
+		remainders[j] = j
 	}
-	if mid == startInclusive {
-		mid++
+	sort.SliceStable(remainders, func(a, b int) bool {
+		ja, jb := remainders[a], remainders[b]
+		ra := float64(n)*weights[names[ja]]/total - float64(counts[ja])
+		rb := float64(n)*weights[names[jb]]/total - float64(counts[jb])
+		return ra > rb
+	})
+	for _, j := range remainders[:n-allocated] {
+		counts[j]++
+	}
+
+	var indices []int
+	for j, s := range names {
+		if counts[j] == 0 {
+			continue
+		}
+		p := make([]float64, len(members[s]))
+		for m, i := range members[s] {
+			p[m] = d.P[i]
+		}
+		t, err := NewAliasTable(&Distribution{p})
+		if err != nil {
+			return nil, err
+		}
+		for c := 0; c < counts[j]; c++ {
+			indices = append(indices, members[s][t.Sample(r)])
+		}
 	}
-	return search(s, cumulative, mid, endExclusive)
+	return indices, nil
 }
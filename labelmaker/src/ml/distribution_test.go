@@ -0,0 +1,152 @@
+package ml
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	for _, p := range [][]float64{
+		{},
+		{0.5, math.NaN()},
+		{0.5, math.Inf(1)},
+		{0.5, -0.5},
+		{0.0, 0.0},
+		{math.MaxFloat64, math.MaxFloat64},
+	} {
+		if err := (&Distribution{p}).Validate(); err == nil {
+			t.Errorf("expected %v to be invalid", p)
+		}
+	}
+	if err := (&Distribution{[]float64{0.0, 2.0, 1.0}}).Validate(); err != nil {
+		t.Errorf("expected unnormalized weights to be valid but was %v", err)
+	}
+}
+
+func TestAliasTable(t *testing.T) {
+	d := &Distribution{[]float64{0.1, 0.0, 0.6, 0.3}}
+	table, err := NewAliasTable(d)
+	if err != nil {
+		t.Fatalf("should have built the table: %v", err)
+	}
+	r := rand.New(rand.NewSource(42))
+	counts := make([]int, len(d.P))
+	const n = 100000
+	for i := 0; i < n; i++ {
+		counts[table.Sample(r)]++
+	}
+	for i, p := range d.P {
+		if f := float64(counts[i]) / n; math.Abs(f-p) > 0.01 {
+			t.Errorf("expected index %d to be drawn %f of the time but was %f", i, p, f)
+		}
+	}
+	if _, err := NewAliasTable(&Distribution{[]float64{1.0, -1.0}}); err == nil {
+		t.Errorf("expected a table of negative weights to be refused")
+	}
+}
+
+func TestSampleWithoutReplacement(t *testing.T) {
+	d := &Distribution{[]float64{0.1, 0.0, 0.6, 0.3}}
+	r := rand.New(rand.NewSource(42))
+	first := 0
+	for i := 0; i < 1000; i++ {
+		indices, err := d.SampleWithoutReplacement(3, r)
+		if err != nil {
+			t.Fatalf("should have drawn 3 indices: %v", err)
+		}
+		seen := make(map[int]bool)
+		for _, j := range indices {
+			if seen[j] || j == 1 {
+				t.Fatalf("expected distinct indices with weight but was %v", indices)
+			}
+			seen[j] = true
+		}
+		if indices[0] == 2 {
+			first++
+		}
+	}
+	if first < 550 || first > 650 {
+		t.Errorf("expected the heaviest index to be drawn first about 600 times but was %d", first)
+	}
+	if _, err := d.SampleWithoutReplacement(4, r); err == nil {
+		t.Errorf("expected drawing more indices than have weight to be refused")
+	}
+	if _, err := d.SampleWithoutReplacement(-1, r); err == nil {
+		t.Errorf("expected drawing a negative number of indices to be refused")
+	}
+}
+
+func TestStratifiedSample(t *testing.T) {
+	d := &Distribution{[]float64{0.05, 0.05, 0.3, 0.3, 0.3, 0.0}}
+	strata := []int{1, 1, 0, 0, 0, 2}
+	indices, err := d.StratifiedSample(strata, 20, rand.New(rand.NewSource(42)))
+	if err != nil {
+		t.Fatalf("should have drawn the sample: %v", err)
+	}
+	counts := make([]int, 3)
+	for _, i := range indices {
+		counts[strata[i]]++
+	}
+	if counts[0] != 18 || counts[1] != 2 || counts[2] != 0 {
+		t.Errorf("expected 18, 2 and 0 from each stratum but was %v", counts)
+	}
+	if _, err := d.StratifiedSample(strata[1:], 20, rand.New(rand.NewSource(42))); err == nil {
+		t.Errorf("expected strata of the wrong length to be refused")
+	}
+	if _, err := d.StratifiedSample(strata, -1, rand.New(rand.NewSource(42))); err == nil {
+		t.Errorf("expected drawing a negative number of indices to be refused")
+	}
+}
+
+func TestAdaBoostRecoversFromUnderflow(t *testing.T) {
+	var dataset []Example
+	for i := 0; i < 8; i++ {
+		color := "yellow"
+		if i%2 == 0 {
+			color = "red"
+		}
+		dataset = append(dataset, &datum{color, "light", Label(color == "red")})
+	}
+	a := NewAdaBoost(dataset, &constantLearner{&reflectedFeature{"Color", "red"}}, rand.New(rand.NewSource(42)))
+	// Weights so small that a perfect round takes them all to zero.
+	for i := range a.D.P {
+		a.D.P[i] = 1e-320
+	}
+	a.Round(8)
+	if err := a.D.Validate(); err != nil {
+		t.Fatalf("expected the distribution to be recovered but was %v", err)
+	}
+	for i, p := range a.D.P {
+		if math.Abs(p-0.125) > 1e-9 {
+			t.Errorf("expected example %d to have weight 0.125 but was %g", i, p)
+		}
+	}
+}
+
+func TestSAMMERecomputesDistribution(t *testing.T) {
+	dataset := []MultiClassExample{
+		&multiClassDatum{datum{"red", "heavy", false}, 0},
+		&multiClassDatum{datum{"red", "light", false}, 0},
+		&multiClassDatum{datum{"yellow", "light", false}, 1},
+		&multiClassDatum{datum{"yellow", "light", false}, 2},
+		&multiClassDatum{datum{"yellow", "heavy", false}, 2},
+	}
+	features := []Feature{
+		&reflectedFeature{"Color", "red"},
+		&reflectedFeature{"Weight", "heavy"},
+	}
+	s := NewSAMME(dataset, 3, NewDecisionTreeBuilder(features, 1), rand.New(rand.NewSource(42)))
+	for i := 0; i < 3; i++ {
+		s.Round(20)
+	}
+	// Recovering from underflow in log space must give the weights
+	// boosting produced.
+	p := append([]float64(nil), s.D.P...)
+	s.recomputeDistribution()
+	for i := range p {
+		if math.Abs(p[i]-s.D.P[i]) > 1e-9 {
+			t.Fatalf("expected recomputed weight of example %d to be %g but was %g", i, p[i], s.D.P[i])
+		}
+	}
+}
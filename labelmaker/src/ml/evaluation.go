@@ -0,0 +1,241 @@
+package ml
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// ConfusionMatrix counts predictions by predicted and actual class.
+type ConfusionMatrix struct {
+	TruePositives  int `json:"tp"`
+	FalsePositives int `json:"fp"`
+	TrueNegatives  int `json:"tn"`
+	FalseNegatives int `json:"fn"`
+}
+
+// CurvePoint is a point on a ROC or precision-recall curve. X and Y
+// are the false positive rate and true positive rate for ROC curves,
+// and recall and precision for precision-recall curves.
+type CurvePoint struct {
+	Threshold float64 `json:"threshold"`
+	X         float64 `json:"x"`
+	Y         float64 `json:"y"`
+}
+
+// Evaluation summarizes how well a classifier does on a set of
+// examples. Metrics which are undefined, like precision when nothing
+// is predicted positive, are reported as 0.
+type Evaluation struct {
+	Examples  int             `json:"examples"`
+	Confusion ConfusionMatrix `json:"confusion"`
+	Accuracy  float64         `json:"accuracy"`
+	Precision float64         `json:"precision"`
+	Recall    float64         `json:"recall"`
+	F1        float64         `json:"f1"`
+	// LogLoss is the mean negative log likelihood of the labels,
+	// treating the logistic function of the score as the
+	// probability of the positive class.
+	LogLoss float64      `json:"log_loss"`
+	ROC     []CurvePoint `json:"roc"`
+	ROCAUC  float64      `json:"roc_auc"`
+	PR      []CurvePoint `json:"pr"`
+	// PRAUC is the average precision, the area under the
+	// precision-recall curve with step interpolation.
+	PRAUC float64 `json:"pr_auc"`
+}
+
+// ratio returns n/d, or 0 if d is 0.
+func ratio(n float64, d float64) float64 {
+	if d == 0.0 {
+		return 0.0
+	}
+	return n / d
+}
+
+type scoredLabel struct {
+	score float64
+	label Label
+}
+
+// Evaluate predicts every example with c and measures the
+// results. Scores greater than 0 are predictions of the positive
+// class.
+func Evaluate(c Classifier, examples []Example) *Evaluation {
+	scored := make([]scoredLabel, len(examples))
+	for i, example := range examples {
+		scored[i] = scoredLabel{c.Predict(example), example.Label()}
+	}
+	return evaluateScores(scored)
+}
+
+func evaluateScores(scored []scoredLabel) *Evaluation {
+	ev := &Evaluation{Examples: len(scored)}
+	m := &ev.Confusion
+	logLoss := 0.0
+	for _, s := range scored {
+		predicted := s.score > 0.0
+		switch {
+		case predicted && bool(s.label):
+			m.TruePositives++
+		case predicted:
+			m.FalsePositives++
+		case bool(s.label):
+			m.FalseNegatives++
+		default:
+			m.TrueNegatives++
+		}
+
+		// -log(sigmoid(y*score)), computed without overflow.
+		z := float64OfLabel(s.label) * s.score
+		if z > 0 {
+			logLoss += math.Log1p(math.Exp(-z))
+		} else {
+			logLoss += -z + math.Log1p(math.Exp(z))
+		}
+	}
+
+	npos := float64(m.TruePositives + m.FalseNegatives)
+	nneg := float64(m.FalsePositives + m.TrueNegatives)
+	ev.Accuracy = ratio(float64(m.TruePositives+m.TrueNegatives), npos+nneg)
+	ev.Precision = ratio(float64(m.TruePositives), float64(m.TruePositives+m.FalsePositives))
+	ev.Recall = ratio(float64(m.TruePositives), npos)
+	ev.F1 = ratio(2*ev.Precision*ev.Recall, ev.Precision+ev.Recall)
+	ev.LogLoss = ratio(logLoss, npos+nneg)
+
+	// Sweep the threshold from the highest score to the lowest. Ties
+	// are treated as one step so the curves do not depend on the
+	// order of the examples.
+	sort.Slice(scored, func(i, j int) bool {
+		return scored[i].score > scored[j].score
+	})
+	tp, fp := 0.0, 0.0
+	lastTPR, lastFPR, lastRecall := 0.0, 0.0, 0.0
+	for i := 0; i < len(scored); {
+		threshold := scored[i].score
+		for ; i < len(scored) && scored[i].score == threshold; i++ {
+			if scored[i].label {
+				tp++
+			} else {
+				fp++
+			}
+		}
+		tpr, fpr := ratio(tp, npos), ratio(fp, nneg)
+		ev.ROCAUC += (fpr - lastFPR) * (tpr + lastTPR) / 2.0
+		ev.ROC = append(ev.ROC, CurvePoint{threshold, fpr, tpr})
+
+		recall, precision := tpr, tp/(tp+fp)
+		ev.PRAUC += (recall - lastRecall) * precision
+		ev.PR = append(ev.PR, CurvePoint{threshold, recall, precision})
+		lastTPR, lastFPR, lastRecall = tpr, fpr, recall
+	}
+	if npos == 0.0 || nneg == 0.0 {
+		ev.ROCAUC = 0.0
+	}
+	return ev
+}
+
+// String returns a human-readable summary of the evaluation.
+func (ev *Evaluation) String() string {
+	m := ev.Confusion
+	return fmt.Sprintf("%d examples: accuracy=%f precision=%f recall=%f f1=%f roc_auc=%f pr_auc=%f log_loss=%f\n"+
+		"               predicted +  predicted -\n"+
+		"  actual +     %11d  %11d\n"+
+		"  actual -     %11d  %11d",
+		ev.Examples, ev.Accuracy, ev.Precision, ev.Recall, ev.F1, ev.ROCAUC, ev.PRAUC, ev.LogLoss,
+		m.TruePositives, m.FalseNegatives, m.FalsePositives, m.TrueNegatives)
+}
+
+// WriteJSON writes the evaluation, including its curves, as JSON.
+func (ev *Evaluation) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(ev)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// EvaluationMetrics lists the names of the scalar metrics which
+// Metric returns, in the order they are written out.
+var EvaluationMetrics = []string{"accuracy", "precision", "recall", "f1", "log_loss", "roc_auc", "pr_auc"}
+
+// IsEvaluationMetric returns whether name is one of EvaluationMetrics.
+func IsEvaluationMetric(name string) bool {
+	for _, metric := range EvaluationMetrics {
+		if metric == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Metric returns the scalar metric with the given name, one of
+// EvaluationMetrics.
+func (ev *Evaluation) Metric(name string) float64 {
+	switch name {
+	case "accuracy":
+		return ev.Accuracy
+	case "precision":
+		return ev.Precision
+	case "recall":
+		return ev.Recall
+	case "f1":
+		return ev.F1
+	case "log_loss":
+		return ev.LogLoss
+	case "roc_auc":
+		return ev.ROCAUC
+	case "pr_auc":
+		return ev.PRAUC
+	default:
+		panic(fmt.Sprintf("ml: unknown metric \"%s\"", name))
+	}
+}
+
+// WriteCSV writes the scalar metrics as metric,value rows.
+func (ev *Evaluation) WriteCSV(w io.Writer) error {
+	m := ev.Confusion
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"metric", "value"})
+	for _, row := range []struct {
+		name  string
+		value int
+	}{
+		{"examples", ev.Examples},
+		{"tp", m.TruePositives},
+		{"fp", m.FalsePositives},
+		{"tn", m.TrueNegatives},
+		{"fn", m.FalseNegatives},
+	} {
+		cw.Write([]string{row.name, strconv.Itoa(row.value)})
+	}
+	for _, name := range EvaluationMetrics {
+		cw.Write([]string{name, formatFloat(ev.Metric(name))})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteCurvesCSV writes the ROC and precision-recall curves as
+// curve,threshold,x,y rows.
+func (ev *Evaluation) WriteCurvesCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"curve", "threshold", "x", "y"})
+	for _, curve := range []struct {
+		name   string
+		points []CurvePoint
+	}{
+		{"roc", ev.ROC},
+		{"pr", ev.PR},
+	} {
+		for _, p := range curve.points {
+			cw.Write([]string{curve.name, formatFloat(p.Threshold), formatFloat(p.X), formatFloat(p.Y)})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,279 @@
+package ml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Decision is a step of an example's path through a decision tree:
+// the feature a node tested and which way the example went.
+type Decision struct {
+	Feature   string `json:"feature"`
+	Condition string `json:"condition"`
+	// Weight is the fraction of the tree's vote which went this way.
+	// It is less than 1 below a node which blended its branches
+	// because the example's value was missing or unseen.
+	Weight float64 `json:"weight"`
+}
+
+// String returns the decision as text, quoting the feature because
+// features made from words may contain any character.
+func (d Decision) String() string {
+	if d.Weight < 1.0 {
+		return fmt.Sprintf("%q%s [%.0f%%]", d.Feature, d.Condition, 100.0*d.Weight)
+	}
+	return fmt.Sprintf("%q%s", d.Feature, d.Condition)
+}
+
+// RoundExplanation explains one classifier of an ensemble's vote.
+type RoundExplanation struct {
+	Round int `json:"round"`
+	// Weight is the classifier's weight in the ensemble, and
+	// Prediction what it predicted for the example.
+	Weight     float64 `json:"weight"`
+	Prediction float64 `json:"prediction"`
+	// Contribution is Weight*Prediction.
+	Contribution float64 `json:"contribution"`
+	// Path is the decisions the tree made, depth first. Where a node
+	// blended its branches, the decisions down each branch follow
+	// one another, weighted by the branch's share of the vote.
+	Path []Decision `json:"path"`
+	// shares is the fraction of Contribution each feature is
+	// responsible for.
+	shares map[string]float64
+}
+
+// FeatureContribution is the part of a score which a feature is
+// responsible for.
+type FeatureContribution struct {
+	Feature      string  `json:"feature"`
+	Contribution float64 `json:"contribution"`
+}
+
+// Explanation explains why a classifier gave an example its score.
+type Explanation struct {
+	Score  float64            `json:"score"`
+	Rounds []RoundExplanation `json:"rounds"`
+	// Features are the contributions of the rounds aggregated by the
+	// features on their paths, largest in magnitude first. A round's
+	// contribution is shared equally between the distinct features it
+	// tested, so that the contributions add up to the score. Where a
+	// tree blended its branches, the contribution is first shared
+	// between the branches by their weights. Rounds which tested no
+	// feature count towards NoFeature.
+	Features []FeatureContribution `json:"features"`
+}
+
+// NoFeature is the name in explanations for the contribution of
+// classifiers which predict the same for every example.
+const NoFeature = "(none)"
+
+// blendedBranches returns the weight of each of the children of the
+// tree node c in its vote for e, or nil if e goes down one branch.
+// ThresholdNodes and CategoryNodes blend their branches for missing
+// and unseen values.
+func blendedBranches(c Classifier, e Example) []float64 {
+	switch n := c.(type) {
+	case *ThresholdNode:
+		if math.IsNaN(n.feature.Predict(e)) {
+			return []float64{1.0 - n.pAbove, n.pAbove}
+		}
+	case *CategoryNode:
+		category := n.feature.Category(e)
+		if i := sort.SearchStrings(n.categories, category); category != "" && i < len(n.categories) && n.categories[i] == category {
+			return nil
+		}
+		sum := 0.0
+		for _, w := range n.weights {
+			sum += w
+		}
+		weights := make([]float64, len(n.weights))
+		for i, w := range n.weights {
+			weights[i] = w / sum
+		}
+		return weights
+	}
+	return nil
+}
+
+// branchCondition describes e going down the ith branch of the tree
+// node c.
+func branchCondition(c Classifier, i int, e Example) string {
+	switch n := c.(type) {
+	case *FeatureNode:
+		return fmt.Sprintf("=%t", i == 0)
+	case *ThresholdNode:
+		op := "<="
+		if i == 1 {
+			op = ">"
+		}
+		condition := fmt.Sprintf("%s%g", op, n.threshold)
+		if math.IsNaN(n.feature.Predict(e)) {
+			condition += " (missing)"
+		}
+		return condition
+	case *CategoryNode:
+		condition := "=" + n.categories[i]
+		if category := n.feature.Category(e); category == "" {
+			condition += " (missing)"
+		} else if category != n.categories[i] {
+			condition += fmt.Sprintf(" (unseen %q)", category)
+		}
+		return condition
+	default:
+		panic(fmt.Sprintf("ml: %T is not a tree node", c))
+	}
+}
+
+// treeFeature returns the name of the feature the tree node c tests.
+func treeFeature(c Classifier) string {
+	switch n := c.(type) {
+	case *FeatureNode:
+		return n.feature.String()
+	case *ThresholdNode:
+		return n.feature.String()
+	case *CategoryNode:
+		return n.feature.String()
+	default:
+		panic(fmt.Sprintf("ml: %T is not a tree node", c))
+	}
+}
+
+// decisionPath follows e through a tree, from the root to the
+// leaves, the way the tree votes: down one branch, or down every
+// branch with weight where the node blends them.
+type decisionPath struct {
+	path []Decision
+	// shares is the fraction of the vote each feature is responsible
+	// for. Every leaf reached shares its weight equally between the
+	// distinct features on the way to it.
+	shares map[string]float64
+}
+
+func newDecisionPath(c Classifier, e Example) *decisionPath {
+	p := &decisionPath{nil, make(map[string]float64)}
+	p.follow(c, e, 1.0, nil)
+	return p
+}
+
+// follow continues the path from c, which carries weight of the vote
+// and was reached by testing features. Classifiers which are
+// Features, such as decision stumps, are one decision.
+func (p *decisionPath) follow(c Classifier, e Example, weight float64, features []string) {
+	switch n := c.(type) {
+	case *FeatureNode, *ThresholdNode, *CategoryNode:
+		feature := treeFeature(c)
+		features = append(features[:len(features):len(features)], feature)
+		children := treeChildren(c)
+		weights := blendedBranches(c, e)
+		if weights == nil {
+			i := treeBranch(c, e)
+			p.path = append(p.path, Decision{feature, branchCondition(c, i, e), weight})
+			p.follow(children[i], e, weight, features)
+			return
+		}
+		for i, w := range weights {
+			if w > 0.0 {
+				p.path = append(p.path, Decision{feature, branchCondition(c, i, e), weight * w})
+				p.follow(children[i], e, weight*w, features)
+			}
+		}
+	case Feature:
+		p.path = append(p.path, Decision{n.String(), fmt.Sprintf("=%t", !math.Signbit(n.Predict(e))), weight})
+		p.share(weight, append(features, n.String()))
+	default:
+		p.share(weight, features)
+	}
+}
+
+// share shares weight equally between the distinct features.
+func (p *decisionPath) share(weight float64, features []string) {
+	var distinct []string
+	seen := make(map[string]bool)
+	for _, f := range features {
+		if !seen[f] {
+			seen[f] = true
+			distinct = append(distinct, f)
+		}
+	}
+	if len(distinct) == 0 {
+		distinct = []string{NoFeature}
+	}
+	for _, f := range distinct {
+		p.shares[f] += weight / float64(len(distinct))
+	}
+}
+
+// explainRound explains the vote of classifier h with weight w.
+func explainRound(round int, w float64, h Classifier, e Example) RoundExplanation {
+	prediction := h.Predict(e)
+	p := newDecisionPath(h, e)
+	return RoundExplanation{round, w, prediction, w * prediction, p.path, p.shares}
+}
+
+func newExplanation(rounds []RoundExplanation) *Explanation {
+	ex := &Explanation{0.0, rounds, nil}
+	byFeature := make(map[string]float64)
+	for _, r := range rounds {
+		ex.Score += r.Contribution
+		for f, share := range r.shares {
+			byFeature[f] += share * r.Contribution
+		}
+	}
+	for f, c := range byFeature {
+		ex.Features = append(ex.Features, FeatureContribution{f, c})
+	}
+	sort.Slice(ex.Features, func(i, j int) bool {
+		ci, cj := math.Abs(ex.Features[i].Contribution), math.Abs(ex.Features[j].Contribution)
+		if ci != cj {
+			return ci > cj
+		}
+		return ex.Features[i].Feature < ex.Features[j].Feature
+	})
+	return ex
+}
+
+// Explain explains the score the ensemble gives e, round by round.
+func (a *AdaBoost) Explain(e Example) *Explanation {
+	rounds := make([]RoundExplanation, len(a.H))
+	for i, h := range a.H {
+		rounds[i] = explainRound(i, a.A[i], h, e)
+	}
+	return newExplanation(rounds)
+}
+
+// ExplainTree explains the prediction of a decision tree for e, as an
+// ensemble of one tree with weight 1.
+func ExplainTree(tree Classifier, e Example) *Explanation {
+	return newExplanation([]RoundExplanation{explainRound(0, 1.0, tree, e)})
+}
+
+// String returns the explanation as text: the score, the features
+// which contributed to it and the path of each round.
+func (ex *Explanation) String() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "score %+f", ex.Score)
+	buf.WriteString("\n  features:")
+	for _, f := range ex.Features {
+		fmt.Fprintf(&buf, "\n    %+f  %q", f.Contribution, f.Feature)
+	}
+	buf.WriteString("\n  rounds:")
+	for _, r := range ex.Rounds {
+		path := make([]string, len(r.Path))
+		for i, d := range r.Path {
+			path[i] = d.String()
+		}
+		fmt.Fprintf(&buf, "\n    %d: %f * %+g = %+f  %s", r.Round, r.Weight, r.Prediction, r.Contribution, strings.Join(path, " -> "))
+	}
+	return buf.String()
+}
+
+// WriteJSON writes the explanation as JSON.
+func (ex *Explanation) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(ex)
+}
@@ -0,0 +1,131 @@
+package ml
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestExplainTree(t *testing.T) {
+	tree := &CategoryNode{shapeFeature{}, []string{"round", "square"}, []Classifier{
+		&ThresholdNode{sizeFeature{}, 5.0, &LeafNode{false}, &LeafNode{true}, 0.75},
+		&LeafNode{false},
+	}, []float64{3.0, 1.0}}
+
+	// Examples with missing or unseen values go down every branch,
+	// as the tree blends its branches' votes for them.
+	for _, c := range []struct {
+		example *attributeDatum
+		path    []Decision
+	}{
+		{&attributeDatum{7.0, "round", true}, []Decision{{"shape", "=round", 1.0}, {"size", ">5", 1.0}}},
+		{&attributeDatum{math.NaN(), "round", true}, []Decision{
+			{"shape", "=round", 1.0},
+			{"size", "<=5 (missing)", 0.25},
+			{"size", ">5 (missing)", 0.75},
+		}},
+		{&attributeDatum{2.0, "oval", false}, []Decision{
+			{"shape", "=round (unseen \"oval\")", 0.75},
+			{"size", "<=5", 0.75},
+			{"shape", "=square (unseen \"oval\")", 0.25},
+		}},
+		{&attributeDatum{math.NaN(), "", true}, []Decision{
+			{"shape", "=round (missing)", 0.75},
+			{"size", "<=5 (missing)", 0.1875},
+			{"size", ">5 (missing)", 0.5625},
+			{"shape", "=square (missing)", 0.25},
+		}},
+		{&attributeDatum{2.0, "square", false}, []Decision{{"shape", "=square", 1.0}}},
+	} {
+		ex := ExplainTree(tree, c.example)
+		if ex.Score != tree.Predict(c.example) {
+			t.Errorf("expected score %f for %v but was %f", tree.Predict(c.example), c.example, ex.Score)
+		}
+		sum := 0.0
+		for _, f := range ex.Features {
+			sum += f.Contribution
+		}
+		if math.Abs(sum-ex.Score) > 1e-9 {
+			t.Errorf("expected the contributions for %v to add up to %f but was %f", c.example, ex.Score, sum)
+		}
+		path := ex.Rounds[0].Path
+		if len(path) != len(c.path) {
+			t.Errorf("expected path %v for %v but was %v", c.path, c.example, path)
+			continue
+		}
+		for i := range path {
+			if path[i] != c.path[i] {
+				t.Errorf("expected path %v for %v but was %v", c.path, c.example, path)
+				break
+			}
+		}
+	}
+
+	// The vote for an unseen shape is shared between the branches by
+	// their weights: three quarters between shape and size, and a
+	// quarter to shape alone.
+	ex := ExplainTree(tree, &attributeDatum{2.0, "oval", false})
+	expected := []FeatureContribution{{"shape", -0.625}, {"size", -0.375}}
+	if len(ex.Features) != len(expected) {
+		t.Fatalf("expected contributions %v but was %v", expected, ex.Features)
+	}
+	for i, f := range ex.Features {
+		if f.Feature != expected[i].Feature || math.Abs(f.Contribution-expected[i].Contribution) > 1e-9 {
+			t.Errorf("expected contributions %v but was %v", expected, ex.Features)
+			break
+		}
+	}
+	if s := ex.Rounds[0].Path[0].String(); s != `"shape"=round (unseen "oval") [75%]` {
+		t.Errorf("expected the decision to show its weight but was %s", s)
+	}
+}
+
+func TestExplainAdaBoost(t *testing.T) {
+	color := &reflectedFeature{"Color", "red"}
+	weight := &reflectedFeature{"Weight", "heavy"}
+	a := &AdaBoost{
+		H: []Classifier{
+			&FeatureNode{color, &FeatureNode{weight, &LeafNode{true}, &LeafNode{false}}, &LeafNode{false}},
+			weight,
+			&LeafNode{false},
+		},
+		A: []float64{1.0, 0.5, 0.25},
+	}
+	e := &datum{"red", "light", false}
+	ex := a.Explain(e)
+	if ex.Score != a.Predict(e) {
+		t.Errorf("expected score %f but was %f", a.Predict(e), ex.Score)
+	}
+	if len(ex.Rounds) != 3 {
+		t.Fatalf("expected 3 rounds but was %d", len(ex.Rounds))
+	}
+	if s := ex.Rounds[0].Path[1].String(); s != `"Weight*heavy"=false` {
+		t.Errorf("expected the first round to test Weight*heavy=false but was %s", s)
+	}
+
+	// The first round's -1 is shared between the features; the
+	// second's -0.5 is all Weight*heavy's.
+	expected := []FeatureContribution{{"Weight*heavy", -1.0}, {"Color*red", -0.5}, {NoFeature, -0.25}}
+	if len(ex.Features) != len(expected) {
+		t.Fatalf("expected contributions %v but was %v", expected, ex.Features)
+	}
+	for i, f := range ex.Features {
+		if f != expected[i] {
+			t.Errorf("expected contributions %v but was %v", expected, ex.Features)
+			break
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := ex.WriteJSON(&buf); err != nil {
+		t.Fatalf("should have written the explanation: %v", err)
+	}
+	var decoded Explanation
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("should have decoded the explanation: %v", err)
+	}
+	if decoded.Score != ex.Score || len(decoded.Rounds) != 3 || len(decoded.Features) != 3 {
+		t.Errorf("expected the decoded explanation to match but was %+v", decoded)
+	}
+}
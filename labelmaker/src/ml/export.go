@@ -0,0 +1,152 @@
+package ml
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// describeNode returns what a tree node shows when exported, the
+// labels of its branches and its children. Classifiers which are
+// Features, like decision stumps, are shown as a node with two leaves.
+func describeNode(c Classifier) (string, []string, []Classifier) {
+	switch n := c.(type) {
+	case *FeatureNode:
+		return n.feature.String(), []string{"true", "false"}, []Classifier{n.positive, n.negative}
+	case *ThresholdNode:
+		return n.feature.String(), []string{fmt.Sprintf("<=%g", n.threshold), fmt.Sprintf(">%g", n.threshold)}, []Classifier{n.below, n.above}
+	case *CategoryNode:
+		branches := make([]string, len(n.categories))
+		for i, category := range n.categories {
+			branches[i] = "=" + category
+		}
+		return n.feature.String(), branches, n.children
+	case Feature:
+		return n.String(), []string{"true", "false"}, []Classifier{&LeafNode{true}, &LeafNode{false}}
+	case *LeafNode:
+		if n.class {
+			return "predict +", nil, nil
+		}
+		return "predict -", nil, nil
+	default:
+		return fmt.Sprintf("%T", c), nil, nil
+	}
+}
+
+// nodeStats returns the statistics an exported node shows for the
+// examples which reach it: their number, class distribution and, for
+// nodes which split, the information gain of the split.
+func nodeStats(examples []Example, parts [][]Example) string {
+	npos, nneg := labelCounts(examples)
+	stats := fmt.Sprintf("n=%d +%d/-%d", len(examples), npos, nneg)
+	if parts != nil && len(examples) > 0 {
+		stats += fmt.Sprintf(" gain=%.4f", splitGain(examples, parts))
+	}
+	return stats
+}
+
+// exportedParts returns the examples which go down each branch of c,
+// or nil if c is a leaf.
+func exportedParts(c Classifier, examples []Example) [][]Example {
+	if _, ok := splitFeature(c); !ok {
+		return nil
+	}
+	return splitExamples(c, examples)
+}
+
+func writeTreeText(buf *bytes.Buffer, c Classifier, examples []Example, indent string, branch string) {
+	label, branches, children := describeNode(c)
+	parts := exportedParts(c, examples)
+	if _, ok := splitFeature(c); ok {
+		label = fmt.Sprintf("%q", label)
+	}
+	fmt.Fprintf(buf, "%s%s%s (%s)\n", indent, branch, label, nodeStats(examples, parts))
+	for i, child := range children {
+		writeTreeText(buf, child, parts[i], indent+"  ", branches[i]+": ")
+	}
+}
+
+// WriteTreeText writes a decision tree as indented text, a line per
+// node. Each node shows the feature it splits on, or its prediction,
+// and the number, class distribution and information gain of the
+// examples which reach it.
+func WriteTreeText(w io.Writer, tree Classifier, examples []Example) error {
+	var buf bytes.Buffer
+	writeTreeText(&buf, tree, examples, "", "")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WriteEnsembleText writes the trees of an ensemble as indented
+// text, each headed by its round and weight.
+func WriteEnsembleText(w io.Writer, a *AdaBoost, examples []Example) error {
+	var buf bytes.Buffer
+	for i, h := range a.H {
+		fmt.Fprintf(&buf, "round %d, weight %f:\n", i, a.A[i])
+		writeTreeText(&buf, h, examples, "  ", "")
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// dotEscape escapes s for a double-quoted Graphviz string. Control
+// characters, which words from issues may contain, become spaces.
+func dotEscape(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' {
+			return ' '
+		}
+		return r
+	}, strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s))
+}
+
+// dotWriter numbers the nodes of the trees it writes, so that several
+// trees can share a graph.
+type dotWriter struct {
+	buf   bytes.Buffer
+	nodes int
+}
+
+func (d *dotWriter) writeTree(c Classifier, examples []Example, indent string) string {
+	id := fmt.Sprintf("n%d", d.nodes)
+	d.nodes++
+	label, branches, children := describeNode(c)
+	parts := exportedParts(c, examples)
+	shape := "ellipse"
+	if children == nil {
+		shape = "box"
+	}
+	fmt.Fprintf(&d.buf, "%s%s [shape=%s, label=\"%s\\n%s\"];\n", indent, id, shape, dotEscape(label), nodeStats(examples, parts))
+	for i, child := range children {
+		childId := d.writeTree(child, parts[i], indent)
+		fmt.Fprintf(&d.buf, "%s%s -> %s [label=\"%s\"];\n", indent, id, childId, dotEscape(branches[i]))
+	}
+	return id
+}
+
+// WriteTreeDOT writes a decision tree as a Graphviz graph, with the
+// same information in each node as WriteTreeText.
+func WriteTreeDOT(w io.Writer, tree Classifier, examples []Example) error {
+	d := &dotWriter{}
+	d.buf.WriteString("digraph tree {\n")
+	d.writeTree(tree, examples, "  ")
+	d.buf.WriteString("}\n")
+	_, err := w.Write(d.buf.Bytes())
+	return err
+}
+
+// WriteEnsembleDOT writes the trees of an ensemble as a Graphviz
+// graph, each in a cluster labelled with its round and weight.
+func WriteEnsembleDOT(w io.Writer, a *AdaBoost, examples []Example) error {
+	d := &dotWriter{}
+	d.buf.WriteString("digraph ensemble {\n")
+	for i, h := range a.H {
+		fmt.Fprintf(&d.buf, "  subgraph cluster_%d {\n    label=\"round %d, weight %f\";\n", i, i, a.A[i])
+		d.writeTree(h, examples, "    ")
+		d.buf.WriteString("  }\n")
+	}
+	d.buf.WriteString("}\n")
+	_, err := w.Write(d.buf.Bytes())
+	return err
+}
@@ -0,0 +1,67 @@
+package ml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteTreeText(t *testing.T) {
+	dataset := []Example{
+		&datum{"red", "heavy", true},
+		&datum{"red", "light", false},
+		&datum{"yellow", "light", false},
+		&datum{"yellow", "heavy", false},
+	}
+	tree := &FeatureNode{&reflectedFeature{"Color", "red"},
+		&FeatureNode{&reflectedFeature{"Weight", "heavy"}, &LeafNode{true}, &LeafNode{false}},
+		&LeafNode{false}}
+
+	var buf bytes.Buffer
+	if err := WriteTreeText(&buf, tree, dataset); err != nil {
+		t.Fatalf("should have written the tree: %v", err)
+	}
+	expected := `"Color*red" (n=4 +1/-3 gain=0.3113)
+  true: "Weight*heavy" (n=2 +1/-1 gain=1.0000)
+    true: predict + (n=1 +1/-0)
+    false: predict - (n=1 +0/-1)
+  false: predict - (n=2 +0/-2)
+`
+	if buf.String() != expected {
+		t.Errorf("expected\n%s\nbut was\n%s", expected, buf.String())
+	}
+}
+
+func TestWriteEnsembleDOT(t *testing.T) {
+	dataset := []Example{
+		&datum{"red", "heavy", true},
+		&datum{"yellow", "light", false},
+	}
+	a := &AdaBoost{
+		H: []Classifier{
+			&FeatureNode{&reflectedFeature{"Color", "red"}, &LeafNode{true}, &LeafNode{false}},
+			&reflectedFeature{"Weight", "\"heavy\""},
+		},
+		A: []float64{1.0, 0.5},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteEnsembleDOT(&buf, a, dataset); err != nil {
+		t.Fatalf("should have written the ensemble: %v", err)
+	}
+	dot := buf.String()
+	for _, s := range []string{
+		"digraph ensemble {",
+		`subgraph cluster_1 {`,
+		`label="round 1, weight 0.500000";`,
+		`n0 [shape=ellipse, label="Color*red\nn=2 +1/-1 gain=1.0000"];`,
+		`n1 [shape=box, label="predict +\nn=1 +1/-0"];`,
+		`n0 -> n1 [label="true"];`,
+		`n3 [shape=ellipse, label="Weight*\"heavy\"\nn=2 +1/-1 gain=0.0000"];`,
+		`n3 -> n5 [label="false"];`,
+	} {
+		if !strings.Contains(dot, s) {
+			t.Errorf("expected the graph to contain %s but was\n%s", s, dot)
+		}
+	}
+}
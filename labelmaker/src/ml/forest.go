@@ -0,0 +1,94 @@
+package ml
+
+import (
+	"math"
+	"math/rand"
+)
+
+// RandomForest averages the votes of decision trees, each built from
+// a bootstrap sample of the examples.
+type RandomForest struct {
+	Trees []Classifier
+	// OOBError is the out-of-bag error rate: the error rate on the
+	// training examples of the trees which were not built from
+	// them. It is an estimate of the error on unseen examples which
+	// needs no held out set. Examples which every tree was built
+	// from are not counted.
+	OOBError float64
+}
+
+// RandomForestLearner builds random forests of trees with a copy of
+// Builder. Each split chooses between FeatureSubset of Builder's
+// binary features, or the square root of the number of them if
+// FeatureSubset is 0.
+type RandomForestLearner struct {
+	Builder       *DecisionTreeBuilder
+	Trees         int
+	FeatureSubset int
+	Rand          *rand.Rand
+}
+
+func NewRandomForestLearner(tb *DecisionTreeBuilder, trees int, r *rand.Rand) *RandomForestLearner {
+	return &RandomForestLearner{tb, trees, 0, r}
+}
+
+func (l *RandomForestLearner) NewClassifier(examples []Example) Classifier {
+	return l.NewClassifierFromMatrix(NewFeatureMatrix(l.Builder.features, examples))
+}
+
+func (l *RandomForestLearner) NewClassifierFromMatrix(m *FeatureMatrix) Classifier {
+	n := len(m.Examples)
+	tb := *l.Builder
+	tb.FeatureSubset = l.FeatureSubset
+	if tb.FeatureSubset == 0 {
+		tb.FeatureSubset = int(math.Ceil(math.Sqrt(float64(len(tb.features)))))
+	}
+	// Give the builder its own source so that the trees do not
+	// depend on how it interleaves with bootstrap sampling.
+	tb.Rand = rand.New(rand.NewSource(l.Rand.Int63()))
+
+	forest := &RandomForest{}
+	// votes[i] is the sum of the out-of-bag votes for example i, and
+	// nvotes[i] how many there were.
+	votes := make([]float64, n)
+	nvotes := make([]int, n)
+	for t := 0; t < l.Trees; t++ {
+		rows := make([]int, n)
+		inBag := NewBitset(n)
+		for i := range rows {
+			rows[i] = l.Rand.Intn(n)
+			inBag.Set(rows[i])
+		}
+		tree := tb.NewClassifierFromMatrix(m.Rows(rows))
+		forest.Trees = append(forest.Trees, tree)
+
+		predicted := predictMatrix(tree, m)
+		fullBitset(n).AndNot(inBag).ForEach(func(i int) {
+			votes[i] += float64OfLabel(Label(predicted.Has(i)))
+			nvotes[i]++
+		})
+	}
+
+	errors, counted := 0, 0
+	for i, e := range m.Examples {
+		if nvotes[i] > 0 {
+			counted++
+			if !math.Signbit(votes[i]) != bool(e.Label()) {
+				errors++
+			}
+		}
+	}
+	if counted > 0 {
+		forest.OOBError = float64(errors) / float64(counted)
+	}
+	return forest
+}
+
+// Predict returns the average vote of the trees, from -1.0 to 1.0.
+func (f *RandomForest) Predict(e Example) float64 {
+	sum := 0.0
+	for _, tree := range f.Trees {
+		sum += tree.Predict(e)
+	}
+	return sum / float64(len(f.Trees))
+}
@@ -0,0 +1,46 @@
+package ml
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestRandomForest(t *testing.T) {
+	// The label is the majority of bits 0, 1 and 2, with 10% noise,
+	// and the other bits are noise.
+	r := rand.New(rand.NewSource(11))
+	var features []Feature
+	for i := 0; i < 9; i++ {
+		features = append(features, bitFeature(i))
+	}
+	var dataset []Example
+	for i := 0; i < 400; i++ {
+		d := &bitsDatum{make([]bool, len(features)), false}
+		for j := range d.bits {
+			d.bits[j] = r.Intn(2) == 0
+		}
+		majority := (d.bits[0] && d.bits[1]) || (d.bits[1] && d.bits[2]) || (d.bits[0] && d.bits[2])
+		d.class = Label(majority != (r.Intn(10) == 0))
+		dataset = append(dataset, d)
+	}
+
+	var forests []*RandomForest
+	for i := 0; i < 2; i++ {
+		learner := NewRandomForestLearner(NewDecisionTreeBuilder(features, 4), 30, rand.New(rand.NewSource(42)))
+		forests = append(forests, learner.NewClassifier(dataset).(*RandomForest))
+	}
+	forest := forests[0]
+	if len(forest.Trees) != 30 {
+		t.Errorf("expected 30 trees but was %d", len(forest.Trees))
+	}
+	if forest.OOBError < 0.05 || forest.OOBError > 0.25 {
+		t.Errorf("expected an out-of-bag error of about 0.1 but was %f", forest.OOBError)
+	}
+	if e := evaluateClassifier(forest, dataset); e > 0.15 {
+		t.Errorf("expected training error of at most 0.15 but was %f", e)
+	}
+	if forests[1].OOBError != forest.OOBError || !reflect.DeepEqual(forests[1].Trees, forest.Trees) {
+		t.Errorf("expected forests built with the same seed to be the same")
+	}
+}
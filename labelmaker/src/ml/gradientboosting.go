@@ -0,0 +1,208 @@
+package ml
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// A Regressor predicts a real value for an example. Unlike a
+// Classifier's, the value is not only meaningful for its sign.
+type Regressor interface {
+	Predict(Example) float64
+}
+
+// A RegressionLearner fits a regressor to the examples of a matrix,
+// minimizing the weighted squared error from the targets.
+type RegressionLearner interface {
+	NewRegressor(m *FeatureMatrix, targets []float64, weights []float64) Regressor
+}
+
+// RegressionLeafNode predicts a constant value.
+type RegressionLeafNode struct {
+	value float64
+}
+
+func (n *RegressionLeafNode) Predict(e Example) float64 {
+	return n.value
+}
+
+// RegressionTreeBuilder builds regression trees which split on binary
+// features. Trees are made of FeatureNodes and RegressionLeafNodes.
+type RegressionTreeBuilder struct {
+	features []Feature
+	maxDepth int
+	// MinLeaf is the fewest examples a leaf may have.
+	MinLeaf int
+	// Parallelism is how many goroutines search for the best split.
+	// The trees built do not depend on it.
+	Parallelism int
+}
+
+func NewRegressionTreeBuilder(fs []Feature, maxDepth int) *RegressionTreeBuilder {
+	return &RegressionTreeBuilder{fs, maxDepth, 5, 1}
+}
+
+func (tb *RegressionTreeBuilder) NewRegressor(m *FeatureMatrix, targets []float64, weights []float64) Regressor {
+	columns := make([]Bitset, len(tb.features))
+	for i, f := range tb.features {
+		columns[i] = m.Column(f)
+	}
+	return tb.build(1, columns, targets, weights, fullBitset(len(m.Examples)))
+}
+
+// weightedSums returns the sums of the weights and of the weighted
+// targets of the examples.
+func weightedSums(examples Bitset, targets []float64, weights []float64) (float64, float64) {
+	sw, swt := 0.0, 0.0
+	examples.ForEach(func(i int) {
+		sw += weights[i]
+		swt += weights[i] * targets[i]
+	})
+	return sw, swt
+}
+
+func (tb *RegressionTreeBuilder) build(depth int, columns []Bitset, targets []float64, weights []float64, examples Bitset) Regressor {
+	n := examples.Count()
+	sw, swt := weightedSums(examples, targets, weights)
+	leaf := &RegressionLeafNode{0.0}
+	if sw > 0.0 {
+		leaf.value = swt / sw
+	}
+	if depth == tb.maxDepth || n < 2*tb.MinLeaf || sw == 0.0 {
+		return leaf
+	}
+
+	// The reduction in weighted squared error from splitting a set
+	// into two is sum(wt)^2/sum(w) of each part less that of the
+	// whole.
+	current := swt * swt / sw
+	best, _ := findBestFeature(len(columns), tb.Parallelism, func(i int) float64 {
+		npos := examples.AndCount(columns[i])
+		if npos < tb.MinLeaf || n-npos < tb.MinLeaf {
+			return 0.0
+		}
+		swPos, swtPos := weightedSums(examples.And(columns[i]), targets, weights)
+		swNeg, swtNeg := sw-swPos, swt-swtPos
+		if swPos <= 0.0 || swNeg <= 0.0 {
+			return 0.0
+		}
+		gain := swtPos*swtPos/swPos + swtNeg*swtNeg/swNeg - current
+		// Ignore rounding error.
+		if gain < 1e-12*math.Abs(current) {
+			return 0.0
+		}
+		return gain
+	})
+	if best == -1 {
+		return leaf
+	}
+
+	pos := examples.And(columns[best])
+	neg := examples.AndNot(columns[best])
+	return &FeatureNode{tb.features[best], tb.build(depth+1, columns, targets, weights, pos), tb.build(depth+1, columns, targets, weights, neg)}
+}
+
+// GradientBoosting fits an additive model of the log odds of the
+// positive class by gradient boosting with logistic loss, as in
+// Friedman, "Greedy Function Approximation: A Gradient Boosting
+// Machine", 2001. Each round fits a regressor to the Newton step
+// towards the labels, which unlike AdaBoost's exponential loss does
+// not let a few mislabeled examples dominate.
+type GradientBoosting struct {
+	Matrix  *FeatureMatrix
+	Learner RegressionLearner
+	// LearningRate shrinks each regressor's contribution.
+	LearningRate float64
+	// Subsample is the fraction of the examples, drawn without
+	// replacement, which each regressor is fitted to.
+	Subsample float64
+	// Bias is the initial log odds, of the prevalence of positive
+	// examples.
+	Bias float64
+	H    []Regressor
+	// F holds the model's current log odds for each example.
+	F    []float64
+	rand *rand.Rand
+}
+
+func NewGradientBoosting(m *FeatureMatrix, learner RegressionLearner, r *rand.Rand) *GradientBoosting {
+	n := len(m.Examples)
+	npos := m.labels.Count()
+	// Smooth the prevalence so that the bias is finite.
+	bias := math.Log((float64(npos) + 0.5) / (float64(n-npos) + 0.5))
+	f := make([]float64, n)
+	for i := range f {
+		f[i] = bias
+	}
+	return &GradientBoosting{m, learner, 0.1, 1.0, bias, nil, f, r}
+}
+
+// minHessian keeps the Newton step finite when the model is very
+// confident.
+const minHessian = 1e-10
+
+func (g *GradientBoosting) Round() {
+	n := len(g.Matrix.Examples)
+	rows := g.rand.Perm(n)
+	if k := int(math.Ceil(g.Subsample * float64(n))); k < n {
+		rows = rows[:k]
+	}
+	sort.Ints(rows)
+
+	// The Newton step for example i is its gradient over its
+	// hessian; weighting each example by its hessian makes the
+	// weighted mean of a leaf the Newton step for the leaf.
+	targets := make([]float64, len(rows))
+	weights := make([]float64, len(rows))
+	for j, i := range rows {
+		p := sigmoid(g.F[i])
+		h := math.Max(p*(1.0-p), minHessian)
+		targets[j] = (float64OfBinaryLabel(Label(g.Matrix.labels.Has(i))) - p) / h
+		weights[j] = h
+	}
+	h := g.Learner.NewRegressor(g.Matrix.Rows(rows), targets, weights)
+	g.H = append(g.H, h)
+	for i, e := range g.Matrix.Examples {
+		g.F[i] += g.LearningRate * h.Predict(e)
+	}
+}
+
+// Predict returns the log odds that e is positive.
+func (g *GradientBoosting) Predict(e Example) float64 {
+	score := g.Bias
+	for _, h := range g.H {
+		score += g.LearningRate * h.Predict(e)
+	}
+	return score
+}
+
+// Evaluates the model on a test set and returns the error rate.
+func (g *GradientBoosting) Evaluate(test []Example) float64 {
+	return evaluateClassifier(g, test)
+}
+
+// GradientBoostingLearner is a Learner which gradient boosts
+// regression trees over Features for a fixed number of rounds.
+type GradientBoostingLearner struct {
+	Features     []Feature
+	Weak         RegressionLearner
+	Rounds       int
+	LearningRate float64
+	Subsample    float64
+	Rand         *rand.Rand
+}
+
+func (l *GradientBoostingLearner) NewClassifier(es []Example) Classifier {
+	return l.NewClassifierFromMatrix(NewFeatureMatrix(l.Features, es))
+}
+
+func (l *GradientBoostingLearner) NewClassifierFromMatrix(m *FeatureMatrix) Classifier {
+	g := NewGradientBoosting(m, l.Weak, l.Rand)
+	g.LearningRate = l.LearningRate
+	g.Subsample = l.Subsample
+	for i := 0; i < l.Rounds; i++ {
+		g.Round()
+	}
+	return g
+}
@@ -0,0 +1,79 @@
+package ml
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestRegressionTree(t *testing.T) {
+	features := []Feature{bitFeature(0), bitFeature(1)}
+	var dataset []Example
+	var targets, weights []float64
+	for i := 0; i < 40; i++ {
+		d := &bitsDatum{[]bool{i%2 == 0, i%4 < 2}, false}
+		dataset = append(dataset, d)
+		// Bit 0 adds 3 to the target; bit 1 does nothing.
+		target := 1.0
+		if d.bits[0] {
+			target += 3.0
+		}
+		targets = append(targets, target)
+		weights = append(weights, 1.0)
+	}
+	m := NewFeatureMatrix(features, dataset)
+	tree := NewRegressionTreeBuilder(features, 3).NewRegressor(m, targets, weights)
+	for i, example := range dataset {
+		if p := tree.Predict(example); math.Abs(p-targets[i]) > 1e-9 {
+			t.Errorf("expected %f for example %d but was %f", targets[i], i, p)
+		}
+	}
+	if _, ok := tree.(*FeatureNode).positive.(*RegressionLeafNode); !ok {
+		t.Errorf("expected the tree not to split on bit 1")
+	}
+
+	// A leaf predicts the weighted mean of its targets.
+	weights[0] = 3.0
+	targets[0] = 8.0
+	tree = NewRegressionTreeBuilder(features, 2).NewRegressor(m, targets, weights)
+	expected := (3.0*8.0 + 19.0*4.0) / 22.0
+	if p := tree.Predict(dataset[2]); math.Abs(p-expected) > 1e-9 {
+		t.Errorf("expected the weighted mean %f but was %f", expected, p)
+	}
+}
+
+func TestGradientBoosting(t *testing.T) {
+	r := rand.New(rand.NewSource(13))
+	var features []Feature
+	for i := 0; i < 5; i++ {
+		features = append(features, bitFeature(i))
+	}
+	var dataset []Example
+	for i := 0; i < 500; i++ {
+		d := &bitsDatum{make([]bool, len(features)), false}
+		for j := range d.bits {
+			d.bits[j] = r.Intn(2) == 0
+		}
+		d.class = Label((d.bits[0] && d.bits[1]) != (r.Intn(10) == 0))
+		dataset = append(dataset, d)
+	}
+	m := NewFeatureMatrix(features, dataset)
+	g := NewGradientBoosting(m, NewRegressionTreeBuilder(features, 3), rand.New(rand.NewSource(42)))
+	g.Subsample = 0.5
+	before := Evaluate(g, dataset).LogLoss
+	for i := 0; i < 50; i++ {
+		g.Round()
+	}
+	ev := Evaluate(g, dataset)
+	if ev.LogLoss >= before {
+		t.Errorf("expected log loss to fall from %f but was %f", before, ev.LogLoss)
+	}
+	if e := g.Evaluate(dataset); e > 0.15 {
+		t.Errorf("expected error of about 0.1 but was %f", e)
+	}
+	for i, example := range dataset {
+		if math.Abs(g.Predict(example)-g.F[i]) > 1e-9 {
+			t.Errorf("expected the tracked score of example %d to be %f but was %f", i, g.Predict(example), g.F[i])
+		}
+	}
+}
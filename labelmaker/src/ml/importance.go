@@ -0,0 +1,313 @@
+package ml
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// FeatureImportance is how much a model relies on a feature.
+type FeatureImportance struct {
+	Feature string `json:"feature"`
+	// Gain is the information gain, in bits, of the nodes which
+	// split on the feature, weighted by the fraction of the examples
+	// which reach them and by the weight of their tree, summed over
+	// the trees.
+	Gain float64 `json:"gain"`
+	// Permutation is how much worse the model's metric is on held
+	// out examples when the feature's values are shuffled between
+	// them.
+	Permutation float64 `json:"permutation"`
+	// Splits is how many nodes split on the feature.
+	Splits int `json:"splits"`
+	// Support is how many of the train examples have the feature:
+	// binary features which are positive, numeric features with a
+	// value and categorical features with a category.
+	Support int `json:"support"`
+}
+
+// splitFeature returns the name of the feature the tree node c splits
+// on, or false if c is a leaf. Classifiers which are Features, like
+// decision stumps, split on themselves.
+func splitFeature(c Classifier) (string, bool) {
+	switch n := c.(type) {
+	case *FeatureNode:
+		return n.feature.String(), true
+	case *ThresholdNode:
+		return n.feature.String(), true
+	case *CategoryNode:
+		return n.feature.String(), true
+	case Feature:
+		return n.String(), true
+	default:
+		return "", false
+	}
+}
+
+// featureSupport returns how many of the examples have the feature
+// the tree node c splits on; see FeatureImportance.Support.
+func featureSupport(c Classifier, examples []Example) int {
+	var has func(Example) bool
+	switch n := c.(type) {
+	case *FeatureNode:
+		has = func(e Example) bool {
+			return !math.Signbit(n.feature.Predict(e))
+		}
+	case *ThresholdNode:
+		has = func(e Example) bool {
+			return !math.IsNaN(n.feature.Predict(e))
+		}
+	case *CategoryNode:
+		has = func(e Example) bool {
+			return n.feature.Category(e) != ""
+		}
+	case Feature:
+		has = func(e Example) bool {
+			return !math.Signbit(n.Predict(e))
+		}
+	default:
+		return 0
+	}
+	support := 0
+	for _, e := range examples {
+		if has(e) {
+			support++
+		}
+	}
+	return support
+}
+
+// splitExamples partitions the examples by which child of the tree
+// node c they go down. A Feature's examples go down two branches,
+// those it predicts positive first, though it has no children.
+func splitExamples(c Classifier, examples []Example) [][]Example {
+	if f, ok := c.(Feature); ok {
+		parts := make([][]Example, 2)
+		for _, e := range examples {
+			if math.Signbit(f.Predict(e)) {
+				parts[1] = append(parts[1], e)
+			} else {
+				parts[0] = append(parts[0], e)
+			}
+		}
+		return parts
+	}
+	return partitionExamples(c, len(treeChildren(c)), examples)
+}
+
+// labelCounts returns the number of positive and negative examples.
+func labelCounts(examples []Example) (int, int) {
+	npos := 0
+	for _, e := range examples {
+		if e.Label() {
+			npos++
+		}
+	}
+	return npos, len(examples) - npos
+}
+
+// splitGain returns the information gain of partitioning the examples
+// into parts.
+func splitGain(examples []Example, parts [][]Example) float64 {
+	npos, nneg := labelCounts(examples)
+	gain := entropy([]int{npos, nneg})
+	for _, part := range parts {
+		if len(part) == 0 {
+			continue
+		}
+		npos, nneg := labelCounts(part)
+		gain -= float64(len(part)) / float64(len(examples)) * entropy([]int{npos, nneg})
+	}
+	return gain
+}
+
+// addGainImportance adds the weighted gain of each node of the tree c
+// on the examples which reach it, out of n examples in all.
+func addGainImportance(importances map[string]*FeatureImportance, c Classifier, w float64, examples []Example, n int) {
+	name, ok := splitFeature(c)
+	if !ok || len(examples) == 0 {
+		return
+	}
+	parts := splitExamples(c, examples)
+	fi := importances[name]
+	if fi == nil {
+		fi = &FeatureImportance{Feature: name}
+		importances[name] = fi
+	}
+	fi.Gain += w * float64(len(examples)) / float64(n) * splitGain(examples, parts)
+	fi.Splits++
+	for i, child := range treeChildren(c) {
+		addGainImportance(importances, child, w, parts[i], n)
+	}
+}
+
+// addSplitNodes records a node of the tree c which splits on each
+// feature.
+func addSplitNodes(nodes map[string]Classifier, c Classifier) {
+	name, ok := splitFeature(c)
+	if !ok {
+		return
+	}
+	if _, seen := nodes[name]; !seen {
+		nodes[name] = c
+	}
+	for _, child := range treeChildren(c) {
+		addSplitNodes(nodes, child)
+	}
+}
+
+// FeatureImportances measures the importance of every feature an
+// ensemble's trees split on. The gain of each node is measured on the
+// train examples, usually those the model was trained on. Permutation
+// importance is measured by metric, one of EvaluationMetrics, on the
+// heldOut examples, which must be comparable, like pointers; it is
+// not measured if there are none. The result is sorted by gain.
+func FeatureImportances(a *AdaBoost, train []Example, heldOut []Example, metric string, r *rand.Rand) []FeatureImportance {
+	byName := make(map[string]*FeatureImportance)
+	nodes := make(map[string]Classifier)
+	for i, h := range a.H {
+		addGainImportance(byName, h, a.A[i], train, len(train))
+		addSplitNodes(nodes, h)
+	}
+	var importances []FeatureImportance
+	for name, fi := range byName {
+		fi.Support = featureSupport(nodes[name], train)
+		importances = append(importances, *fi)
+	}
+	sort.Slice(importances, func(i, j int) bool {
+		if importances[i].Gain != importances[j].Gain {
+			return importances[i].Gain > importances[j].Gain
+		}
+		return importances[i].Feature < importances[j].Feature
+	})
+	if len(heldOut) > 0 {
+		p := newPermuter(a, heldOut, metric)
+		for i := range importances {
+			importances[i].Permutation = p.importance(importances[i].Feature, r)
+		}
+	}
+	return importances
+}
+
+// PermutationImportance returns how much worse a's metric, one of
+// EvaluationMetrics, is on the examples when the values of the named
+// feature are shuffled between them. The examples must be
+// comparable, like pointers.
+func PermutationImportance(a *AdaBoost, feature string, examples []Example, metric string, r *rand.Rand) float64 {
+	return newPermuter(a, examples, metric).importance(feature, r)
+}
+
+// permuter measures permutation importance without predicting with
+// the whole ensemble for each feature: only the rounds which split on
+// a feature are predicted again with it shuffled.
+type permuter struct {
+	a        *AdaBoost
+	examples []Example
+	index    map[Example]int
+	metric   string
+	// scores are the ensemble's scores for the examples, and baseline
+	// its metric.
+	scores   []float64
+	baseline float64
+}
+
+func newPermuter(a *AdaBoost, examples []Example, metric string) *permuter {
+	p := &permuter{a, examples, make(map[Example]int), metric, make([]float64, len(examples)), 0.0}
+	scored := make([]scoredLabel, len(examples))
+	for i, e := range examples {
+		p.index[e] = i
+		p.scores[i] = a.Predict(e)
+		scored[i] = scoredLabel{p.scores[i], e.Label()}
+	}
+	p.baseline = evaluateScores(scored).Metric(metric)
+	return p
+}
+
+func (p *permuter) importance(feature string, r *rand.Rand) float64 {
+	perm := r.Perm(len(p.examples))
+	source := make([]Example, len(p.examples))
+	for i, j := range perm {
+		source[i] = p.examples[j]
+	}
+	scored := make([]scoredLabel, len(p.examples))
+	for i, e := range p.examples {
+		scored[i] = scoredLabel{p.scores[i], e.Label()}
+	}
+	for t, h := range p.a.H {
+		permuted, ok := withPermutedFeature(h, feature, p.index, source)
+		if !ok {
+			continue
+		}
+		for i, e := range p.examples {
+			scored[i].score += p.a.A[t] * (permuted.Predict(e) - h.Predict(e))
+		}
+	}
+	value := evaluateScores(scored).Metric(p.metric)
+	if metricImproves(p.metric, value, p.baseline) {
+		return -math.Abs(value - p.baseline)
+	}
+	return math.Abs(value - p.baseline)
+}
+
+// permutedFeature gives each example the value Feature has for
+// another: example e gets that of source[index[e]].
+type permutedFeature struct {
+	Feature
+	index  map[Example]int
+	source []Example
+}
+
+func (f *permutedFeature) Predict(e Example) float64 {
+	return f.Feature.Predict(f.source[f.index[e]])
+}
+
+type permutedCategoricalFeature struct {
+	CategoricalFeature
+	index  map[Example]int
+	source []Example
+}
+
+func (f *permutedCategoricalFeature) Category(e Example) string {
+	return f.CategoricalFeature.Category(f.source[f.index[e]])
+}
+
+// withPermutedFeature returns a copy of the tree c in which the named
+// feature is permuted, or false if c does not split on it.
+func withPermutedFeature(c Classifier, feature string, index map[Example]int, source []Example) (Classifier, bool) {
+	name, ok := splitFeature(c)
+	if !ok {
+		return c, false
+	}
+	if f, ok := c.(Feature); ok {
+		if name != feature {
+			return c, false
+		}
+		return &permutedFeature{f, index, source}, true
+	}
+
+	children := treeChildren(c)
+	permutedChildren := make([]Classifier, len(children))
+	changed := false
+	for i, child := range children {
+		var ok bool
+		permutedChildren[i], ok = withPermutedFeature(child, feature, index, source)
+		changed = changed || ok
+	}
+	if name != feature {
+		if !changed {
+			return c, false
+		}
+		return withTreeChildren(c, permutedChildren), true
+	}
+	switch n := c.(type) {
+	case *FeatureNode:
+		return &FeatureNode{&permutedFeature{n.feature, index, source}, permutedChildren[0], permutedChildren[1]}, true
+	case *ThresholdNode:
+		return &ThresholdNode{&permutedFeature{n.feature, index, source}, n.threshold, permutedChildren[0], permutedChildren[1], n.pAbove}, true
+	case *CategoryNode:
+		return &CategoryNode{&permutedCategoricalFeature{n.feature, index, source}, n.categories, permutedChildren, n.weights}, true
+	default:
+		panic(fmt.Sprintf("ml: %T is not a tree node", c))
+	}
+}
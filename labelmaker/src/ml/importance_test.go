@@ -0,0 +1,66 @@
+package ml
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestFeatureImportances(t *testing.T) {
+	// Red examples are positive; weight is noise.
+	var dataset []Example
+	for i := 0; i < 40; i++ {
+		color, weight := "yellow", "light"
+		if i%2 == 0 {
+			color = "red"
+		}
+		if i%4 < 2 {
+			weight = "heavy"
+		}
+		dataset = append(dataset, &datum{color, weight, Label(color == "red")})
+	}
+	color := &reflectedFeature{"Color", "red"}
+	weight := &reflectedFeature{"Weight", "heavy"}
+	a := &AdaBoost{
+		H: []Classifier{
+			&FeatureNode{color, &FeatureNode{weight, &LeafNode{true}, &LeafNode{true}}, &LeafNode{false}},
+			color,
+		},
+		A: []float64{1.0, 0.5},
+	}
+
+	importances := FeatureImportances(a, dataset, dataset, "accuracy", rand.New(rand.NewSource(42)))
+	if len(importances) != 2 {
+		t.Fatalf("expected the importance of 2 features but was %v", importances)
+	}
+	c, w := importances[0], importances[1]
+	if c.Feature != "Color*red" || w.Feature != "Weight*heavy" {
+		t.Fatalf("expected Color*red to be more important than Weight*heavy but was %v", importances)
+	}
+	// Color separates the classes, which is 1 bit, in both rounds.
+	if math.Abs(c.Gain-1.5) > 1e-9 {
+		t.Errorf("expected Color*red to have gain 1.5 but was %f", c.Gain)
+	}
+	if math.Abs(w.Gain) > 1e-9 {
+		t.Errorf("expected Weight*heavy to have no gain but was %f", w.Gain)
+	}
+	if c.Splits != 2 || w.Splits != 1 {
+		t.Errorf("expected 2 and 1 splits but was %d and %d", c.Splits, w.Splits)
+	}
+	if c.Support != 20 || w.Support != 20 {
+		t.Errorf("expected support 20 and 20 but was %d and %d", c.Support, w.Support)
+	}
+	if c.Permutation < 0.3 {
+		t.Errorf("expected shuffling Color*red to lose accuracy but lost %f", c.Permutation)
+	}
+	if w.Permutation != 0.0 {
+		t.Errorf("expected shuffling Weight*heavy not to matter but lost %f", w.Permutation)
+	}
+
+	// Shuffling must not change the model.
+	for _, e := range dataset {
+		if p := a.Predict(e); (p > 0.0) != bool(e.Label()) {
+			t.Errorf("expected the model to still classify %v correctly but predicted %f", e, p)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package ml
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+type Optimizer int
+
+const (
+	// SGD is stochastic gradient descent with a learning rate which
+	// decays with each epoch.
+	SGD Optimizer = iota
+	// AdaGrad scales the learning rate of each weight by the size of
+	// its past gradients, so rare features learn faster.
+	AdaGrad
+)
+
+// LogisticRegressionLearner learns L1 and L2 regularized logistic
+// regression over SparseExamples. As is usual for SGD on sparse
+// data, a weight is only regularized when an example has its feature,
+// so weights of rare features are regularized less.
+type LogisticRegressionLearner struct {
+	// Dimension is one more than the largest index of any vector.
+	Dimension int
+	// Features optionally describes the feature at each index, for
+	// inspecting the weights.
+	Features     []Feature
+	Optimizer    Optimizer
+	LearningRate float64
+	L1           float64
+	L2           float64
+	// Epochs is how many times to pass over the examples.
+	Epochs int
+	Rand   *rand.Rand
+}
+
+func NewLogisticRegressionLearner(dimension int, features []Feature, r *rand.Rand) *LogisticRegressionLearner {
+	return &LogisticRegressionLearner{dimension, features, AdaGrad, 0.5, 0.0, 1e-6, 5, r}
+}
+
+// LogisticRegression classifies examples by the log odds of the
+// positive class, Bias plus the dot product of Weights with the
+// example's vector.
+type LogisticRegression struct {
+	Features []Feature
+	Weights  []float64
+	Bias     float64
+}
+
+func (l *LogisticRegressionLearner) NewClassifier(examples []Example) Classifier {
+	return l.NewWeightedClassifier(examples, UniformDistribution(len(examples)).P)
+}
+
+func (l *LogisticRegressionLearner) NewWeightedClassifier(examples []Example, weights []float64) Classifier {
+	lr := &LogisticRegression{l.Features, make([]float64, l.Dimension), 0.0}
+	// Squared gradients so far, for AdaGrad. The last is the bias's.
+	squares := make([]float64, l.Dimension+1)
+	for i := range squares {
+		squares[i] = 1e-8
+	}
+	rate := func(i int, g float64, epoch int) float64 {
+		if l.Optimizer == AdaGrad {
+			squares[i] += g * g
+			return l.LearningRate / math.Sqrt(squares[i])
+		}
+		return l.LearningRate / float64(epoch+1)
+	}
+
+	// Scale the weights so that an example of average weight takes
+	// a full step.
+	scale := float64(len(examples))
+	for epoch := 0; epoch < l.Epochs; epoch++ {
+		for _, j := range l.Rand.Perm(len(examples)) {
+			e := examples[j]
+			v := vectorOf(e)
+			// The gradient of the log loss with respect to the score.
+			g := (sigmoid(lr.score(v)) - float64OfBinaryLabel(e.Label())) * weights[j] * scale
+			if g == 0.0 {
+				continue
+			}
+			for k, i := range v.Indices {
+				if i >= l.Dimension {
+					continue
+				}
+				gi := g*v.Values[k] + l.L2*lr.Weights[i]
+				eta := rate(i, gi, epoch)
+				w := lr.Weights[i] - eta*gi
+				// Apply the L1 penalty by shrinking towards zero,
+				// without crossing it, so that weights can be
+				// exactly zero.
+				lr.Weights[i] = math.Copysign(math.Max(0.0, math.Abs(w)-eta*l.L1), w)
+			}
+			lr.Bias -= rate(l.Dimension, g, epoch) * g
+		}
+	}
+	return lr
+}
+
+// sigmoid is the logistic function, computed so that it does not
+// overflow for large |z|.
+func sigmoid(z float64) float64 {
+	if z >= 0.0 {
+		return 1.0 / (1.0 + math.Exp(-z))
+	}
+	ez := math.Exp(z)
+	return ez / (1.0 + ez)
+}
+
+func float64OfBinaryLabel(label Label) float64 {
+	if label {
+		return 1.0
+	}
+	return 0.0
+}
+
+func (lr *LogisticRegression) score(v SparseVector) float64 {
+	score := lr.Bias
+	for k, i := range v.Indices {
+		if i < len(lr.Weights) {
+			score += v.Values[k] * lr.Weights[i]
+		}
+	}
+	return score
+}
+
+// Predict returns the log odds that e is positive.
+func (lr *LogisticRegression) Predict(e Example) float64 {
+	return lr.score(vectorOf(e))
+}
+
+type FeatureWeight struct {
+	Feature Feature
+	Weight  float64
+}
+
+// FeatureWeights returns the features with non-zero weights, largest
+// in magnitude first. It returns nil if the learner was not given
+// the features.
+func (lr *LogisticRegression) FeatureWeights() []FeatureWeight {
+	if lr.Features == nil {
+		return nil
+	}
+	var fws []FeatureWeight
+	for i, w := range lr.Weights {
+		if w != 0.0 {
+			fws = append(fws, FeatureWeight{lr.Features[i], w})
+		}
+	}
+	sort.SliceStable(fws, func(i, j int) bool {
+		return math.Abs(fws[i].Weight) > math.Abs(fws[j].Weight)
+	})
+	return fws
+}
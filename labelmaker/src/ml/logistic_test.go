@@ -0,0 +1,69 @@
+package ml
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestLogisticRegression(t *testing.T) {
+	// Index 0 predicts the label, index 1 is noise and index 2 is
+	// never present.
+	r := rand.New(rand.NewSource(5))
+	var dataset []Example
+	for i := 0; i < 500; i++ {
+		label := Label(r.Intn(2) == 0)
+		var indices []int
+		if bool(label) != (r.Intn(10) == 0) {
+			indices = append(indices, 0)
+		}
+		if r.Intn(2) == 0 {
+			indices = append(indices, 1)
+		}
+		values := make([]float64, len(indices))
+		for j := range values {
+			values[j] = 1.0
+		}
+		dataset = append(dataset, sparse(indices, values, label))
+	}
+	features := []Feature{bitFeature(0), bitFeature(1), bitFeature(2)}
+
+	for _, optimizer := range []Optimizer{SGD, AdaGrad} {
+		learner := NewLogisticRegressionLearner(3, features, rand.New(rand.NewSource(42)))
+		learner.Optimizer = optimizer
+		learner.L1 = 1e-3
+		lr := learner.NewClassifier(dataset).(*LogisticRegression)
+		if e := evaluateClassifier(lr, dataset); e > 0.15 {
+			t.Errorf("expected error of about 0.1 with optimizer %d but was %f", optimizer, e)
+		}
+		// The odds are about 9:1 either way.
+		if w := lr.Weights[0]; math.Abs(w-2.0*math.Log(9.0)) > 1.0 {
+			t.Errorf("expected weight about %f with optimizer %d but was %f", 2.0*math.Log(9.0), optimizer, w)
+		}
+		fws := lr.FeatureWeights()
+		if len(fws) == 0 || fws[0].Feature != bitFeature(0) {
+			t.Errorf("expected bit 0 to have the largest weight but weights were %v", fws)
+		}
+		for _, fw := range fws {
+			if fw.Feature == bitFeature(2) {
+				t.Errorf("expected an absent feature to have no weight but was %f", fw.Weight)
+			}
+		}
+	}
+}
+
+func TestLogisticRegressionHonorsWeights(t *testing.T) {
+	// Two identical examples with different labels; the weights
+	// decide which way the model leans.
+	dataset := []Example{
+		sparse([]int{0}, []float64{1.0}, true),
+		sparse([]int{0}, []float64{1.0}, false),
+	}
+	learner := NewLogisticRegressionLearner(1, nil, rand.New(rand.NewSource(42)))
+	if p := learner.NewWeightedClassifier(dataset, []float64{0.8, 0.2}).Predict(dataset[0]); p <= 0.0 {
+		t.Errorf("expected a positive score but was %f", p)
+	}
+	if p := learner.NewWeightedClassifier(dataset, []float64{0.2, 0.8}).Predict(dataset[0]); p >= 0.0 {
+		t.Errorf("expected a negative score but was %f", p)
+	}
+}
@@ -0,0 +1,262 @@
+package ml
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Bitset is a set of example indices.
+type Bitset []uint64
+
+// NewBitset returns an empty set which can hold indices less than n.
+func NewBitset(n int) Bitset {
+	return make(Bitset, (n+63)/64)
+}
+
+func (b Bitset) Set(i int) {
+	b[i/64] |= 1 << uint(i%64)
+}
+
+func (b Bitset) Has(i int) bool {
+	return b[i/64]&(1<<uint(i%64)) != 0
+}
+
+// Count returns the number of indices in the set.
+func (b Bitset) Count() int {
+	n := 0
+	for _, w := range b {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+// AndCount returns the size of the intersection of b and c.
+func (b Bitset) AndCount(c Bitset) int {
+	n := 0
+	for i, w := range b {
+		n += bits.OnesCount64(w & c[i])
+	}
+	return n
+}
+
+// AndCount3 returns the size of the intersection of b, c and d.
+func (b Bitset) AndCount3(c Bitset, d Bitset) int {
+	n := 0
+	for i, w := range b {
+		n += bits.OnesCount64(w & c[i] & d[i])
+	}
+	return n
+}
+
+func (b Bitset) And(c Bitset) Bitset {
+	r := make(Bitset, len(b))
+	for i, w := range b {
+		r[i] = w & c[i]
+	}
+	return r
+}
+
+func (b Bitset) AndNot(c Bitset) Bitset {
+	r := make(Bitset, len(b))
+	for i, w := range b {
+		r[i] = w &^ c[i]
+	}
+	return r
+}
+
+func (b Bitset) Or(c Bitset) Bitset {
+	r := make(Bitset, len(b))
+	for i, w := range b {
+		r[i] = w | c[i]
+	}
+	return r
+}
+
+func (b Bitset) Xor(c Bitset) Bitset {
+	r := make(Bitset, len(b))
+	for i, w := range b {
+		r[i] = w ^ c[i]
+	}
+	return r
+}
+
+// ForEach calls f with each index in the set, in increasing order.
+func (b Bitset) ForEach(f func(i int)) {
+	for i, w := range b {
+		for w != 0 {
+			f(i*64 + bits.TrailingZeros64(w))
+			w &= w - 1
+		}
+	}
+}
+
+// fullBitset returns the set of all indices less than n.
+func fullBitset(n int) Bitset {
+	b := NewBitset(n)
+	for i := range b {
+		b[i] = math.MaxUint64
+	}
+	if n%64 != 0 {
+		b[len(b)-1] = 1<<uint(n%64) - 1
+	}
+	return b
+}
+
+// FeatureMatrix records which examples each feature is positive for,
+// so that learners can count examples with bit operations instead of
+// calling Feature.Predict over and over.
+type FeatureMatrix struct {
+	Features []Feature
+	Examples []Example
+	columns  []Bitset
+	labels   Bitset
+	index    map[Feature]int
+}
+
+func newFeatureMatrix(fs []Feature, es []Example, columns []Bitset) *FeatureMatrix {
+	index := make(map[Feature]int)
+	for i, f := range fs {
+		index[f] = i
+	}
+	return &FeatureMatrix{fs, es, columns, labelBitset(es), index}
+}
+
+func labelBitset(es []Example) Bitset {
+	labels := NewBitset(len(es))
+	for i, e := range es {
+		if e.Label() {
+			labels.Set(i)
+		}
+	}
+	return labels
+}
+
+// NewFeatureMatrix predicts every feature for every example.
+func NewFeatureMatrix(fs []Feature, es []Example) *FeatureMatrix {
+	columns := make([]Bitset, len(fs))
+	for i, f := range fs {
+		columns[i] = predictBitset(f, es)
+	}
+	return newFeatureMatrix(fs, es, columns)
+}
+
+// NewFeatureMatrixFromPostings makes a matrix from postings, the
+// indices of the examples each feature is positive for. This is
+// cheaper than NewFeatureMatrix when the caller can enumerate the
+// positive features of each example directly.
+func NewFeatureMatrixFromPostings(fs []Feature, es []Example, postings [][]int) *FeatureMatrix {
+	columns := make([]Bitset, len(fs))
+	for i, posting := range postings {
+		columns[i] = NewBitset(len(es))
+		for _, j := range posting {
+			columns[i].Set(j)
+		}
+	}
+	return newFeatureMatrix(fs, es, columns)
+}
+
+func predictBitset(c Classifier, es []Example) Bitset {
+	b := NewBitset(len(es))
+	for i, e := range es {
+		if !math.Signbit(c.Predict(e)) {
+			b.Set(i)
+		}
+	}
+	return b
+}
+
+// Column returns the set of examples f is positive for. f need not
+// be one of the matrix's features, but it is much cheaper if it is.
+func (m *FeatureMatrix) Column(f Feature) Bitset {
+	if i, ok := m.index[f]; ok {
+		return m.columns[i]
+	}
+	if mc, ok := f.(matrixClassifier); ok {
+		return mc.predictMatrix(m)
+	}
+	return predictBitset(f, m.Examples)
+}
+
+// Support returns how many examples feature i is positive for.
+func (m *FeatureMatrix) Support(i int) int {
+	return m.columns[i].Count()
+}
+
+// Rows returns a matrix of the same features over the examples at the
+// given indices. Indices may be repeated.
+func (m *FeatureMatrix) Rows(rows []int) *FeatureMatrix {
+	es := make([]Example, len(rows))
+	for j, i := range rows {
+		es[j] = m.Examples[i]
+	}
+	columns := make([]Bitset, len(m.columns))
+	for f, column := range m.columns {
+		columns[f] = NewBitset(len(rows))
+		for j, i := range rows {
+			if column.Has(i) {
+				columns[f].Set(j)
+			}
+		}
+	}
+	return &FeatureMatrix{m.Features, es, columns, labelBitset(es), m.index}
+}
+
+// Relabel returns a matrix which shares the features of m but has
+// different examples, which must correspond one-to-one with the
+// examples of m but may have different labels; see Relabel.
+func (m *FeatureMatrix) Relabel(es []Example) *FeatureMatrix {
+	return &FeatureMatrix{m.Features, es, m.columns, labelBitset(es), m.index}
+}
+
+// A matrixClassifier can classify every example in a matrix at once.
+type matrixClassifier interface {
+	// predictMatrix returns the set of examples which are classified
+	// positive.
+	predictMatrix(m *FeatureMatrix) Bitset
+}
+
+// predictMatrix returns the set of examples in m which c classifies
+// positive.
+func predictMatrix(c Classifier, m *FeatureMatrix) Bitset {
+	if mc, ok := c.(matrixClassifier); ok {
+		return mc.predictMatrix(m)
+	}
+	if f, ok := c.(Feature); ok {
+		return m.Column(f)
+	}
+	return predictBitset(c, m.Examples)
+}
+
+func (f *andFeature) predictMatrix(m *FeatureMatrix) Bitset {
+	return m.Column(f.f1).And(m.Column(f.f2))
+}
+
+func (f *FeatureNegater) predictMatrix(m *FeatureMatrix) Bitset {
+	return fullBitset(len(m.Examples)).AndNot(m.Column(f.Feature))
+}
+
+// A MatrixLearner can learn from the examples of a FeatureMatrix.
+type MatrixLearner interface {
+	NewClassifierFromMatrix(*FeatureMatrix) Classifier
+}
+
+// A WeightedMatrixLearner can learn from the weighted examples of a
+// FeatureMatrix.
+type WeightedMatrixLearner interface {
+	NewWeightedClassifierFromMatrix(m *FeatureMatrix, weights []float64) Classifier
+}
+
+// evaluateClassifierOnMatrix is evaluateClassifierWeighted for
+// examples in a matrix. It also returns the examples classified
+// positive.
+func evaluateClassifierOnMatrix(c Classifier, m *FeatureMatrix, d *Distribution, parallelism int) (float64, Bitset) {
+	predicted := predictMatrix(c, m)
+	mispredicted := predicted.Xor(m.labels)
+	misclassifications := parallelSum(len(m.Examples), parallelism, func(i int) float64 {
+		if mispredicted.Has(i) {
+			return d.P[i]
+		}
+		return 0.0
+	})
+	return misclassifications, predicted
+}
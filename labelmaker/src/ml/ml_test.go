@@ -2,6 +2,7 @@ package ml
 
 import (
 	"fmt"
+	"math"
 	"math/rand"
 	"reflect"
 	"testing"
@@ -73,3 +74,300 @@ func TestDecisionStump(t *testing.T) {
 		t.Errorf("expected decision stump to split on Weight*heavy but split on %v", stump)
 	}
 }
+
+type scoredDatum struct {
+	score float64
+	class Label
+}
+
+func (d *scoredDatum) Label() Label {
+	return d.class
+}
+
+type scoreClassifier struct{}
+
+func (scoreClassifier) Predict(e Example) float64 {
+	return e.(*scoredDatum).score
+}
+
+func TestEvaluate(t *testing.T) {
+	dataset := []Example{
+		&scoredDatum{2.0, true},
+		&scoredDatum{1.0, false},
+		&scoredDatum{0.5, true},
+		&scoredDatum{-1.0, false},
+		&scoredDatum{-2.0, true},
+		&scoredDatum{-3.0, false},
+	}
+	ev := Evaluate(scoreClassifier{}, dataset)
+	expected := ConfusionMatrix{2, 1, 2, 1}
+	if ev.Confusion != expected {
+		t.Errorf("expected confusion matrix %v but was %v", expected, ev.Confusion)
+	}
+	if ev.Precision != 2.0/3.0 || ev.Recall != 2.0/3.0 {
+		t.Errorf("expected precision and recall of 2/3 but were %f, %f", ev.Precision, ev.Recall)
+	}
+	// 6 of the 9 positive, negative pairs are ordered correctly.
+	if math.Abs(ev.ROCAUC-6.0/9.0) > 1e-12 {
+		t.Errorf("expected ROC AUC of 2/3 but was %f", ev.ROCAUC)
+	}
+	// Average precision is (1 + 2/3 + 3/5) / 3.
+	if math.Abs(ev.PRAUC-(1.0+2.0/3.0+3.0/5.0)/3.0) > 1e-12 {
+		t.Errorf("expected average precision of 0.7556 but was %f", ev.PRAUC)
+	}
+	for _, name := range EvaluationMetrics {
+		if !IsEvaluationMetric(name) {
+			t.Errorf("expected %s to be a metric", name)
+		}
+	}
+	if IsEvaluationMetric("auc") {
+		t.Errorf("expected auc not to be a metric")
+	}
+}
+
+type multiClassDatum struct {
+	datum
+	class int
+}
+
+func (d *multiClassDatum) Class() int {
+	return d.class
+}
+
+func TestMultiClassDecisionTree(t *testing.T) {
+	dataset := []MultiClassExample{
+		&multiClassDatum{datum{"red", "heavy", false}, 0},
+		&multiClassDatum{datum{"red", "light", false}, 0},
+		&multiClassDatum{datum{"yellow", "light", false}, 1},
+		&multiClassDatum{datum{"yellow", "light", false}, 1},
+		&multiClassDatum{datum{"yellow", "heavy", false}, 2},
+	}
+	features := []Feature{
+		&reflectedFeature{"Weight", "heavy"},
+		&reflectedFeature{"Color", "red"},
+	}
+	tree := NewDecisionTreeBuilder(features, 3).NewMultiClassClassifier(dataset, 3)
+	root, ok := tree.(*MultiClassFeatureNode)
+	if !ok || root.feature != features[1] {
+		t.Fatalf("expected the tree to split on Color*red first but was %v", tree)
+	}
+	for i, example := range dataset {
+		if class := tree.PredictClass(example); class != example.Class() {
+			t.Errorf("expected example %d to be class %d but was %d", i, example.Class(), class)
+		}
+	}
+}
+
+func TestSAMME(t *testing.T) {
+	// Class 0 is red, class 1 is yellow and light, class 2 is
+	// yellow and heavy.
+	dataset := []MultiClassExample{
+		&multiClassDatum{datum{"red", "heavy", false}, 0},
+		&multiClassDatum{datum{"red", "light", false}, 0},
+		&multiClassDatum{datum{"yellow", "light", false}, 1},
+		&multiClassDatum{datum{"yellow", "light", false}, 1},
+		&multiClassDatum{datum{"yellow", "heavy", false}, 2},
+	}
+	features := []Feature{
+		&reflectedFeature{"Color", "red"},
+		&reflectedFeature{"Weight", "heavy"},
+	}
+
+	r := rand.New(rand.NewSource(42))
+	booster := NewSAMME(dataset, 3, NewDecisionTreeBuilder(features, 3), r)
+	for i := 0; i < 3; i++ {
+		booster.Round(20)
+	}
+	if e := booster.Evaluate(dataset); e != 0.0 {
+		t.Errorf("expected SAMME to classify the training set perfectly but error was %f", e)
+	}
+	ranked := booster.Rank(dataset[4])
+	if len(ranked) != 3 || ranked[0] != 2 {
+		t.Errorf("expected class 2 to be ranked first but ranking was %v", ranked)
+	}
+}
+
+func TestStratifiedKFold(t *testing.T) {
+	var dataset []Example
+	for i := 0; i < 20; i++ {
+		dataset = append(dataset, &datum{"red", "light", i < 5})
+	}
+	folds := StratifiedKFold(dataset, 5, rand.New(rand.NewSource(42)))
+	seen := make(map[int]bool)
+	for _, fold := range folds {
+		if len(fold) != 4 {
+			t.Errorf("expected folds of 4 examples but was %v", fold)
+		}
+		npos := 0
+		for _, i := range fold {
+			seen[i] = true
+			if dataset[i].Label() {
+				npos++
+			}
+		}
+		if npos != 1 {
+			t.Errorf("expected one positive example per fold but fold %v had %d", fold, npos)
+		}
+	}
+	if len(seen) != len(dataset) {
+		t.Errorf("expected every example to be held out once but %d were", len(seen))
+	}
+}
+
+func TestKFoldRefusesTooFewFolds(t *testing.T) {
+	for _, k := range []int{-1, 0, 1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected %d folds to be refused", k)
+				}
+			}()
+			KFold(10, k, rand.New(rand.NewSource(42)))
+		}()
+	}
+}
+
+func TestDecisionTreeOnMatrix(t *testing.T) {
+	dataset := []Example{
+		&datum{"red", "heavy", true},
+		&datum{"red", "light", false},
+		&datum{"yellow", "light", false},
+		&datum{"yellow", "heavy", false},
+		&datum{"yellow", "light", false},
+	}
+	features := []Feature{
+		&reflectedFeature{"Color", "red"},
+		&reflectedFeature{"Weight", "heavy"},
+	}
+	m := NewFeatureMatrix(features, dataset)
+	if n := m.Support(1); n != 2 {
+		t.Errorf("expected Weight*heavy to be positive for 2 examples but was %d", n)
+	}
+
+	tree := NewDecisionTreeBuilder(features, 3).NewClassifierFromMatrix(m)
+	predicted := predictMatrix(tree, m)
+	for i, example := range dataset {
+		p := tree.Predict(example)
+		if Label(p > 0.0) != example.Label() {
+			t.Errorf("expected tree to classify example %d as %v but was %f", i, example.Label(), p)
+		}
+		if predicted.Has(i) != (p > 0.0) {
+			t.Errorf("expected matrix prediction of example %d to agree with Predict", i)
+		}
+	}
+}
+
+type bitsDatum struct {
+	bits  []bool
+	class Label
+}
+
+func (d *bitsDatum) Label() Label {
+	return d.class
+}
+
+type bitFeature int
+
+func (f bitFeature) String() string {
+	return fmt.Sprintf("bit%d", int(f))
+}
+
+func (f bitFeature) Predict(e Example) float64 {
+	return float64OfLabel(Label(e.(*bitsDatum).bits[f]))
+}
+
+func TestAdaBoostDoesNotDependOnParallelism(t *testing.T) {
+	// Enough examples and features to span several blocks.
+	r := rand.New(rand.NewSource(7))
+	var features []Feature
+	for i := 0; i < 2*featureBlockSize+10; i++ {
+		features = append(features, bitFeature(i))
+	}
+	var dataset []Example
+	for i := 0; i < 2*exampleBlockSize+10; i++ {
+		d := &bitsDatum{make([]bool, len(features)), false}
+		for j := range d.bits {
+			d.bits[j] = r.Intn(4) == 0
+		}
+		d.class = Label(d.bits[3] != d.bits[300] || r.Intn(10) == 0)
+		dataset = append(dataset, d)
+	}
+	m := NewFeatureMatrix(features, dataset)
+
+	var boosters []*AdaBoost
+	for _, parallelism := range []int{1, 3, 8} {
+		treeBuilder := NewDecisionTreeBuilder(features, 3)
+		treeBuilder.Parallelism = parallelism
+		booster := NewAdaBoostOnMatrix(m, treeBuilder, rand.New(rand.NewSource(42)))
+		booster.Parallelism = parallelism
+		for i := 0; i < 5; i++ {
+			booster.Round(1000)
+		}
+		boosters = append(boosters, booster)
+	}
+	for _, booster := range boosters[1:] {
+		if !reflect.DeepEqual(booster.A, boosters[0].A) || !reflect.DeepEqual(booster.D.P, boosters[0].D.P) {
+			t.Errorf("expected boosting to give the same results with parallelism %d as with 1", booster.Parallelism)
+		}
+	}
+}
+
+func TestWeightedDecisionTree(t *testing.T) {
+	dataset := []Example{
+		&datum{"red", "heavy", true},
+		&datum{"red", "light", false},
+		&datum{"yellow", "heavy", false},
+		&datum{"yellow", "light", false},
+	}
+	features := []Feature{
+		&reflectedFeature{"Color", "red"},
+		&reflectedFeature{"Weight", "heavy"},
+	}
+	tb := NewDecisionTreeBuilder(features, 2)
+	// Each feature separates one negative example from the positive
+	// one; the weights decide which is worth separating.
+	for _, c := range []struct {
+		weights  []float64
+		expected Feature
+	}{
+		{[]float64{0.25, 0.5, 0.125, 0.125}, features[1]},
+		{[]float64{0.25, 0.125, 0.5, 0.125}, features[0]},
+	} {
+		tree := tb.NewWeightedClassifier(dataset, c.weights).(*FeatureNode)
+		if tree.feature != c.expected {
+			t.Errorf("expected tree with weights %v to split on %v but split on %v", c.weights, c.expected, tree.feature)
+		}
+	}
+}
+
+func TestAdaBoostReweight(t *testing.T) {
+	r := rand.New(rand.NewSource(17))
+	var features []Feature
+	for i := 0; i < 6; i++ {
+		features = append(features, bitFeature(i))
+	}
+	var dataset []Example
+	for i := 0; i < 300; i++ {
+		d := &bitsDatum{make([]bool, len(features)), false}
+		for j := range d.bits {
+			d.bits[j] = r.Intn(2) == 0
+		}
+		d.class = Label((d.bits[0] || d.bits[1]) && d.bits[2])
+		dataset = append(dataset, d)
+	}
+	for _, onMatrix := range []bool{false, true} {
+		var booster *AdaBoost
+		if onMatrix {
+			booster = NewAdaBoostOnMatrix(NewFeatureMatrix(features, dataset), NewDecisionTreeBuilder(features, 2), rand.New(rand.NewSource(42)))
+		} else {
+			booster = NewAdaBoost(dataset, NewDecisionTreeBuilder(features, 2), rand.New(rand.NewSource(42)))
+		}
+		booster.Reweight = true
+		for i := 0; i < 10; i++ {
+			booster.Round(0)
+		}
+		if e := booster.Evaluate(dataset); e > 0.0 {
+			t.Errorf("expected boosting reweighted stumps to fit the data exactly but error was %f", e)
+		}
+	}
+}
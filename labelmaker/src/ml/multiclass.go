@@ -0,0 +1,155 @@
+package ml
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// A MultiClassExample belongs to exactly one of several classes.
+type MultiClassExample interface {
+	Example
+	// Class returns the example's class, from 0 to one less than
+	// the number of classes.
+	Class() int
+}
+
+type MultiClassLearner interface {
+	NewMultiClassClassifier(examples []MultiClassExample, nclasses int) MultiClassClassifier
+}
+
+type MultiClassClassifier interface {
+	// PredictClass returns the most likely class of the example.
+	PredictClass(Example) int
+}
+
+// SAMME is the multi-class generalization of AdaBoost described in
+// Zhu et al., "Multi-class AdaBoost", 2009.
+type SAMME struct {
+	Examples   []MultiClassExample
+	NumClasses int
+	Learner    MultiClassLearner
+	D          *Distribution
+	H          []MultiClassClassifier
+	A          []float64
+	rand       *rand.Rand
+	// Parallelism is how many goroutines evaluate and reweight the
+	// examples each round. The model built does not depend on it.
+	Parallelism int
+}
+
+func NewSAMME(es []MultiClassExample, nclasses int, learner MultiClassLearner, r *rand.Rand) *SAMME {
+	return &SAMME{
+		es,
+		nclasses,
+		learner,
+		UniformDistribution(len(es)),
+		nil,
+		nil,
+		r,
+		1,
+	}
+}
+
+func evaluateMultiClassClassifierWeighted(c MultiClassClassifier, examples []MultiClassExample, d *Distribution, parallelism int) float64 {
+	return parallelSum(len(examples), parallelism, func(i int) float64 {
+		if c.PredictClass(examples[i]) != examples[i].Class() {
+			return d.P[i]
+		}
+		return 0.0
+	})
+}
+
+func (s *SAMME) Round(nexamples int) {
+	t, err := NewAliasTable(s.D)
+	if err != nil {
+		panic(fmt.Sprintf("ml: can not sample examples: %v", err))
+	}
+	var examples []MultiClassExample
+	for i := 0; i < nexamples; i++ {
+		examples = append(examples, s.Examples[t.Sample(s.rand)])
+	}
+
+	h := s.Learner.NewMultiClassClassifier(examples, s.NumClasses)
+
+	// A weak learner only has to do better than guessing, which is
+	// right 1/K of the time. One which does worse gets no say.
+	e_t := evaluateMultiClassClassifierWeighted(h, s.Examples, s.D, s.Parallelism)
+	e_t = math.Max(e_t, math.SmallestNonzeroFloat64)
+	a_t := math.Max(0.0, math.Log1p(-e_t)-math.Log(e_t)+math.Log(float64(s.NumClasses-1)))
+	parallelBlocks(len(s.Examples), exampleBlockSize, s.Parallelism, func(block int, start int, end int) {
+		for i := start; i < end; i++ {
+			if h.PredictClass(s.Examples[i]) != s.Examples[i].Class() {
+				s.D.P[i] *= math.Exp(a_t)
+			}
+		}
+	})
+	s.H = append(s.H, h)
+	s.A = append(s.A, a_t)
+	s.D.normalize(s.Parallelism)
+	if s.D.Validate() != nil {
+		// After many rounds the weights can all underflow to zero, or
+		// their sum overflow; recover them in log space.
+		s.recomputeDistribution()
+	}
+}
+
+// recomputeDistribution sets D to the distribution boosting the
+// rounds in H over the examples would have produced, starting from
+// the uniform distribution.
+func (s *SAMME) recomputeDistribution() {
+	s.D = UniformDistribution(len(s.Examples))
+	parallelBlocks(len(s.Examples), exampleBlockSize, s.Parallelism, func(block int, start int, end int) {
+		for i := start; i < end; i++ {
+			s.D.P[i] = 0.0
+			for t, h := range s.H {
+				if h.PredictClass(s.Examples[i]) != s.Examples[i].Class() {
+					s.D.P[i] += s.A[t]
+				}
+			}
+		}
+	})
+
+	// Shift the exponents by the largest one so that they can not
+	// overflow.
+	maxExponent := math.Inf(-1)
+	for _, p := range s.D.P {
+		maxExponent = math.Max(maxExponent, p)
+	}
+	for i := range s.D.P {
+		s.D.P[i] = math.Exp(s.D.P[i] - maxExponent)
+	}
+	s.D.normalize(s.Parallelism)
+}
+
+// Scores returns the total weight of the votes for each class.
+func (s *SAMME) Scores(e Example) []float64 {
+	scores := make([]float64, s.NumClasses)
+	for i, h := range s.H {
+		scores[h.PredictClass(e)] += s.A[i]
+	}
+	return scores
+}
+
+func (s *SAMME) PredictClass(e Example) int {
+	return s.Rank(e)[0]
+}
+
+// Rank returns every class, most likely first.
+func (s *SAMME) Rank(e Example) []int {
+	scores := s.Scores(e)
+	classes := make([]int, len(scores))
+	for i := range classes {
+		classes[i] = i
+	}
+	sort.SliceStable(classes, func(i, j int) bool {
+		return scores[classes[i]] > scores[classes[j]]
+	})
+	return classes
+}
+
+// Evaluates the classifier on a test set and returns the error rate.
+func (s *SAMME) Evaluate(test []MultiClassExample) float64 {
+	return evaluateMultiClassClassifierWeighted(s, test, UniformDistribution(len(test)), s.Parallelism)
+}
@@ -0,0 +1,112 @@
+package ml
+
+import (
+	"math"
+)
+
+type NaiveBayesModel int
+
+const (
+	// MultinomialNaiveBayes models the vector values as counts of
+	// each word.
+	MultinomialNaiveBayes NaiveBayesModel = iota
+	// BernoulliNaiveBayes models only whether each value is
+	// positive, and takes absent words into account.
+	BernoulliNaiveBayes
+)
+
+// NaiveBayesLearner learns Naive Bayes classifiers over
+// SparseExamples, with Laplace smoothing.
+type NaiveBayesLearner struct {
+	Model NaiveBayesModel
+	// Dimension is one more than the largest index of any vector.
+	Dimension int
+	// Alpha is the smoothing pseudo-count; 1 is Laplace smoothing.
+	Alpha float64
+}
+
+func NewNaiveBayesLearner(model NaiveBayesModel, dimension int) *NaiveBayesLearner {
+	return &NaiveBayesLearner{model, dimension, 1.0}
+}
+
+// NaiveBayes classifies examples by the log odds of the positive
+// class. The log odds are only "calibrated-ish": Naive Bayes assumes
+// the words are independent, which makes it overconfident.
+type NaiveBayes struct {
+	Model NaiveBayesModel
+	// Prior is the log odds of the positive class.
+	Prior float64
+	// Weights are the log odds contributed by each index: for the
+	// multinomial model, per unit of value; for the Bernoulli model,
+	// for a positive value rather than a zero one.
+	Weights []float64
+	// Bias is the log odds contributed if every value is zero, which
+	// is only non-zero for the Bernoulli model.
+	Bias float64
+}
+
+func (l *NaiveBayesLearner) NewClassifier(examples []Example) Classifier {
+	// counts[c][i] is the total value of index i in examples of
+	// class c, or for the Bernoulli model, the number of examples
+	// in which it is positive.
+	var counts [2][]float64
+	var totals, n [2]float64
+	for c := range counts {
+		counts[c] = make([]float64, l.Dimension)
+	}
+	for _, e := range examples {
+		c := 0
+		if e.Label() {
+			c = 1
+		}
+		n[c]++
+		v := vectorOf(e)
+		for j, i := range v.Indices {
+			if l.Model == BernoulliNaiveBayes {
+				if v.Values[j] > 0.0 {
+					counts[c][i]++
+				}
+			} else {
+				counts[c][i] += v.Values[j]
+				totals[c] += v.Values[j]
+			}
+		}
+	}
+
+	nb := &NaiveBayes{l.Model, math.Log((n[1] + l.Alpha) / (n[0] + l.Alpha)), make([]float64, l.Dimension), 0.0}
+	for i := range nb.Weights {
+		switch l.Model {
+		case BernoulliNaiveBayes:
+			p0 := (counts[0][i] + l.Alpha) / (n[0] + 2.0*l.Alpha)
+			p1 := (counts[1][i] + l.Alpha) / (n[1] + 2.0*l.Alpha)
+			nb.Weights[i] = math.Log(p1/(1.0-p1)) - math.Log(p0/(1.0-p0))
+			nb.Bias += math.Log((1.0 - p1) / (1.0 - p0))
+		default:
+			p0 := (counts[0][i] + l.Alpha) / (totals[0] + l.Alpha*float64(l.Dimension))
+			p1 := (counts[1][i] + l.Alpha) / (totals[1] + l.Alpha*float64(l.Dimension))
+			nb.Weights[i] = math.Log(p1 / p0)
+		}
+	}
+	return nb
+}
+
+// Predict returns the log odds that e is positive. Indices beyond the
+// classifier's dimension, such as words which were not in the
+// training vocabulary, are ignored.
+func (nb *NaiveBayes) Predict(e Example) float64 {
+	score := nb.Prior + nb.Bias
+	v := vectorOf(e)
+	for j, i := range v.Indices {
+		if i >= len(nb.Weights) {
+			continue
+		}
+		if nb.Model == BernoulliNaiveBayes {
+			if v.Values[j] > 0.0 {
+				score += nb.Weights[i]
+			}
+		} else {
+			score += v.Values[j] * nb.Weights[i]
+		}
+	}
+	return score
+}
@@ -0,0 +1,69 @@
+package ml
+
+import (
+	"math"
+	"testing"
+)
+
+type sparseDatum struct {
+	vector SparseVector
+	label  Label
+}
+
+func (d *sparseDatum) Label() Label {
+	return d.label
+}
+
+func (d *sparseDatum) Vector() SparseVector {
+	return d.vector
+}
+
+func sparse(indices []int, values []float64, label Label) *sparseDatum {
+	return &sparseDatum{SparseVector{indices, values}, label}
+}
+
+// Index 0 is "ball", 1 is "bug" and 2 is "crash".
+var naiveBayesDataset = []Example{
+	sparse([]int{1, 2}, []float64{2.0, 1.0}, true),
+	sparse([]int{2}, []float64{1.0}, true),
+	sparse([]int{0}, []float64{1.0}, false),
+	sparse([]int{0, 1}, []float64{1.0, 1.0}, false),
+}
+
+func TestMultinomialNaiveBayes(t *testing.T) {
+	nb := NewNaiveBayesLearner(MultinomialNaiveBayes, 3).NewClassifier(naiveBayesDataset)
+	for i, example := range naiveBayesDataset {
+		if Label(nb.Predict(example) > 0.0) != example.Label() {
+			t.Errorf("expected example %d to be classified %v", i, example.Label())
+		}
+	}
+	// P(bug|+) = (2+1)/(4+3) and P(bug|-) = (1+1)/(3+3).
+	expected := math.Log((3.0 / 7.0) / (2.0 / 6.0))
+	if p := nb.Predict(sparse([]int{1}, []float64{1.0}, true)); math.Abs(p-expected) > 1e-9 {
+		t.Errorf("expected log odds %f but was %f", expected, p)
+	}
+	if p := nb.Predict(sparse([]int{7}, []float64{1.0}, true)); p != 0.0 {
+		t.Errorf("expected unknown words to be ignored but log odds were %f", p)
+	}
+}
+
+func TestBernoulliNaiveBayes(t *testing.T) {
+	nb := NewNaiveBayesLearner(BernoulliNaiveBayes, 3).NewClassifier(naiveBayesDataset)
+	for i, example := range naiveBayesDataset {
+		if Label(nb.Predict(example) > 0.0) != example.Label() {
+			t.Errorf("expected example %d to be classified %v", i, example.Label())
+		}
+	}
+	// The only evidence for an empty document is the absence of
+	// words: P(no ball|+) = 3/4, P(no bug|+) = 2/4, P(no crash|+) =
+	// 1/4 and P(no ball|-) = 1/4, P(no bug|-) = 2/4, P(no crash|-) =
+	// 3/4.
+	if p := nb.Predict(sparse(nil, nil, true)); math.Abs(p) > 1e-9 {
+		t.Errorf("expected log odds 0 but was %f", p)
+	}
+	// Relabeled examples are unwrapped to get their vectors.
+	relabeled := Relabel(naiveBayesDataset[:1], func(Example) Label { return false })
+	if p, expected := nb.Predict(relabeled[0]), nb.Predict(naiveBayesDataset[0]); p != expected {
+		t.Errorf("expected relabeled example to have log odds %f but was %f", expected, p)
+	}
+}
@@ -0,0 +1,94 @@
+package ml
+
+import (
+	"sync"
+)
+
+// Work is divided into blocks of a fixed size, independent of the
+// level of parallelism, and per-block results are combined in block
+// order. This keeps results, including floating point sums, the same
+// bit for bit however many goroutines do the work.
+const (
+	featureBlockSize = 256
+	exampleBlockSize = 4096
+)
+
+// parallelBlocks calls f for each block of blockSize indices in
+// [0, n), with block numbers counting from 0. Blocks are processed by
+// up to parallelism goroutines; 0 or 1 processes them serially.
+func parallelBlocks(n int, blockSize int, parallelism int, f func(block int, start int, end int)) {
+	nblocks := (n + blockSize - 1) / blockSize
+	do := func(block int) {
+		start := block * blockSize
+		end := start + blockSize
+		if end > n {
+			end = n
+		}
+		f(block, start, end)
+	}
+
+	if parallelism <= 1 || nblocks <= 1 {
+		for block := 0; block < nblocks; block++ {
+			do(block)
+		}
+		return
+	}
+
+	blocks := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < parallelism && w < nblocks; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for block := range blocks {
+				do(block)
+			}
+		}()
+	}
+	for block := 0; block < nblocks; block++ {
+		blocks <- block
+	}
+	close(blocks)
+	wg.Wait()
+}
+
+// parallelSum sums term(i) for i in [0, n).
+func parallelSum(n int, parallelism int, term func(i int) float64) float64 {
+	sums := make([]float64, (n+exampleBlockSize-1)/exampleBlockSize)
+	parallelBlocks(n, exampleBlockSize, parallelism, func(block int, start int, end int) {
+		for i := start; i < end; i++ {
+			sums[block] += term(i)
+		}
+	})
+	sum := 0.0
+	for _, s := range sums {
+		sum += s
+	}
+	return sum
+}
+
+// findBestFeature returns the index of the feature with the highest
+// positive gain, and the gain, or -1 if no feature has positive
+// gain. Ties go to the lowest index.
+func findBestFeature(nfeatures int, parallelism int, gain func(i int) float64) (int, float64) {
+	nblocks := (nfeatures + featureBlockSize - 1) / featureBlockSize
+	bests := make([]int, nblocks)
+	gains := make([]float64, nblocks)
+	parallelBlocks(nfeatures, featureBlockSize, parallelism, func(block int, start int, end int) {
+		best, bestGain := -1, 0.0
+		for i := start; i < end; i++ {
+			if g := gain(i); g > bestGain {
+				best, bestGain = i, g
+			}
+		}
+		bests[block], gains[block] = best, bestGain
+	})
+
+	best, bestGain := -1, 0.0
+	for block := range bests {
+		if gains[block] > bestGain {
+			best, bestGain = bests[block], gains[block]
+		}
+	}
+	return best, bestGain
+}
@@ -0,0 +1,571 @@
+package ml
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"reflect"
+)
+
+// modelFormatVersion is written into every saved model. Bump it when
+// the format changes incompatibly. Version 2 added Settings; models of
+// version 1 did not record how their features were made, so they are
+// refused rather than used with features which may not match.
+const modelFormatVersion = 2
+
+// savedModel is the top-level on-disk representation of a model.
+type savedModel struct {
+	Version int       `json:"version"`
+	Model   *envelope `json:"model"`
+	// Settings are how the program which saved the model made the
+	// examples' features, which it needs to make them the same way
+	// again.
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+// envelope tags the saved form of a feature or classifier with its
+// kind so that it can be decoded again.
+type envelope struct {
+	Kind string          `json:"kind"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+type featureCodec struct {
+	kind   string
+	encode func(Feature) (interface{}, error)
+	decode func(json.RawMessage) (Feature, error)
+}
+
+var featureCodecsByKind = make(map[string]*featureCodec)
+var featureCodecsByType = make(map[reflect.Type]*featureCodec)
+
+// RegisterFeature makes features with the same type as prototype
+// saveable. kind names the feature in saved models, so it must stay
+// the same across releases. encode returns a value which is
+// marshalled as JSON; decode receives that JSON and rebuilds the
+// feature. RegisterFeature panics if kind or the type of prototype is
+// already registered.
+func RegisterFeature(kind string, prototype Feature, encode func(Feature) (interface{}, error), decode func(json.RawMessage) (Feature, error)) {
+	t := reflect.TypeOf(prototype)
+	if _, ok := featureCodecsByKind[kind]; ok {
+		panic(fmt.Sprintf("ml: feature kind \"%s\" registered twice", kind))
+	}
+	if _, ok := featureCodecsByType[t]; ok {
+		panic(fmt.Sprintf("ml: feature type %v registered twice", t))
+	}
+	codec := &featureCodec{kind, encode, decode}
+	featureCodecsByKind[kind] = codec
+	featureCodecsByType[t] = codec
+}
+
+type categoricalFeatureCodec struct {
+	kind   string
+	encode func(CategoricalFeature) (interface{}, error)
+	decode func(json.RawMessage) (CategoricalFeature, error)
+}
+
+var categoricalFeatureCodecsByKind = make(map[string]*categoricalFeatureCodec)
+var categoricalFeatureCodecsByType = make(map[reflect.Type]*categoricalFeatureCodec)
+
+// RegisterCategoricalFeature is RegisterFeature for categorical
+// features. Categorical features have their own kinds, so a kind may
+// name both a feature and a categorical feature.
+func RegisterCategoricalFeature(kind string, prototype CategoricalFeature, encode func(CategoricalFeature) (interface{}, error), decode func(json.RawMessage) (CategoricalFeature, error)) {
+	t := reflect.TypeOf(prototype)
+	if _, ok := categoricalFeatureCodecsByKind[kind]; ok {
+		panic(fmt.Sprintf("ml: categorical feature kind \"%s\" registered twice", kind))
+	}
+	if _, ok := categoricalFeatureCodecsByType[t]; ok {
+		panic(fmt.Sprintf("ml: categorical feature type %v registered twice", t))
+	}
+	codec := &categoricalFeatureCodec{kind, encode, decode}
+	categoricalFeatureCodecsByKind[kind] = codec
+	categoricalFeatureCodecsByType[t] = codec
+}
+
+func init() {
+	RegisterFeature("and", &andFeature{}, encodeAndFeature, decodeAndFeature)
+	RegisterFeature("not", &FeatureNegater{}, encodeFeatureNegater, decodeFeatureNegater)
+	RegisterFeature("vector", &VectorFeature{}, func(f Feature) (interface{}, error) {
+		return f.(*VectorFeature).Index, nil
+	}, func(data json.RawMessage) (Feature, error) {
+		var index int
+		err := json.Unmarshal(data, &index)
+		return &VectorFeature{index}, err
+	})
+}
+
+type savedAndFeature struct {
+	F1 *envelope `json:"f1"`
+	F2 *envelope `json:"f2"`
+}
+
+func encodeAndFeature(f Feature) (interface{}, error) {
+	and := f.(*andFeature)
+	f1, err := encodeFeature(and.f1)
+	if err != nil {
+		return nil, err
+	}
+	f2, err := encodeFeature(and.f2)
+	if err != nil {
+		return nil, err
+	}
+	return &savedAndFeature{f1, f2}, nil
+}
+
+func decodeAndFeature(data json.RawMessage) (Feature, error) {
+	var saved savedAndFeature
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, err
+	}
+	f1, err := decodeFeature(saved.F1)
+	if err != nil {
+		return nil, err
+	}
+	f2, err := decodeFeature(saved.F2)
+	if err != nil {
+		return nil, err
+	}
+	return &andFeature{f1, f2}, nil
+}
+
+func encodeFeatureNegater(f Feature) (interface{}, error) {
+	return encodeFeature(f.(*FeatureNegater).Feature)
+}
+
+func decodeFeatureNegater(data json.RawMessage) (Feature, error) {
+	var saved envelope
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, err
+	}
+	f, err := decodeFeature(&saved)
+	if err != nil {
+		return nil, err
+	}
+	return &FeatureNegater{f}, nil
+}
+
+func newEnvelope(kind string, v interface{}) (*envelope, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return &envelope{kind, data}, nil
+}
+
+func encodeFeature(f Feature) (*envelope, error) {
+	codec, ok := featureCodecsByType[reflect.TypeOf(f)]
+	if !ok {
+		return nil, fmt.Errorf("Feature %s has unregistered type %T", f, f)
+	}
+	v, err := codec.encode(f)
+	if err != nil {
+		return nil, fmt.Errorf("Encoding feature %s: %v", f, err)
+	}
+	return newEnvelope(codec.kind, v)
+}
+
+func decodeFeature(e *envelope) (Feature, error) {
+	if e == nil {
+		return nil, fmt.Errorf("Missing feature")
+	}
+	codec, ok := featureCodecsByKind[e.Kind]
+	if !ok {
+		return nil, fmt.Errorf("Unknown feature kind \"%s\"; is it registered?", e.Kind)
+	}
+	f, err := codec.decode(e.Data)
+	if err != nil {
+		return nil, fmt.Errorf("Decoding %s feature: %v", e.Kind, err)
+	}
+	return f, nil
+}
+
+func encodeCategoricalFeature(f CategoricalFeature) (*envelope, error) {
+	codec, ok := categoricalFeatureCodecsByType[reflect.TypeOf(f)]
+	if !ok {
+		return nil, fmt.Errorf("Categorical feature %s has unregistered type %T", f, f)
+	}
+	v, err := codec.encode(f)
+	if err != nil {
+		return nil, fmt.Errorf("Encoding categorical feature %s: %v", f, err)
+	}
+	return newEnvelope(codec.kind, v)
+}
+
+func decodeCategoricalFeature(e *envelope) (CategoricalFeature, error) {
+	if e == nil {
+		return nil, fmt.Errorf("Missing categorical feature")
+	}
+	codec, ok := categoricalFeatureCodecsByKind[e.Kind]
+	if !ok {
+		return nil, fmt.Errorf("Unknown categorical feature kind \"%s\"; is it registered?", e.Kind)
+	}
+	f, err := codec.decode(e.Data)
+	if err != nil {
+		return nil, fmt.Errorf("Decoding %s categorical feature: %v", e.Kind, err)
+	}
+	return f, nil
+}
+
+type savedAdaBoost struct {
+	H []*envelope `json:"h"`
+	A []float64   `json:"a"`
+}
+
+type savedFeatureNode struct {
+	Feature  *envelope `json:"feature"`
+	Positive *envelope `json:"positive"`
+	Negative *envelope `json:"negative"`
+}
+
+type savedThresholdNode struct {
+	Feature   *envelope `json:"feature"`
+	Threshold float64   `json:"threshold"`
+	Below     *envelope `json:"below"`
+	Above     *envelope `json:"above"`
+	PAbove    float64   `json:"p_above"`
+}
+
+type savedCategoryNode struct {
+	Feature    *envelope   `json:"feature"`
+	Categories []string    `json:"categories"`
+	Children   []*envelope `json:"children"`
+	Weights    []float64   `json:"weights"`
+}
+
+type savedLeafNode struct {
+	Class bool `json:"class"`
+}
+
+type savedCalibratedClassifier struct {
+	Classifier *envelope `json:"classifier"`
+	Calibrator *envelope `json:"calibrator"`
+}
+
+func encodeCalibrator(c Calibrator) (*envelope, error) {
+	switch c := c.(type) {
+	case *PlattCalibrator:
+		return newEnvelope("platt", c)
+	case *IsotonicCalibrator:
+		return newEnvelope("isotonic", c)
+	default:
+		return nil, fmt.Errorf("Calibrator of type %T can not be saved", c)
+	}
+}
+
+func decodeCalibrator(e *envelope) (Calibrator, error) {
+	if e == nil {
+		return nil, fmt.Errorf("Missing calibrator")
+	}
+	switch e.Kind {
+	case "platt":
+		var c PlattCalibrator
+		if err := json.Unmarshal(e.Data, &c); err != nil {
+			return nil, err
+		}
+		return &c, nil
+	case "isotonic":
+		var c IsotonicCalibrator
+		if err := json.Unmarshal(e.Data, &c); err != nil {
+			return nil, err
+		}
+		if len(c.Scores) != len(c.Probabilities) {
+			return nil, fmt.Errorf("Isotonic calibrator has %d scores but %d probabilities", len(c.Scores), len(c.Probabilities))
+		}
+		return &c, nil
+	default:
+		return nil, fmt.Errorf("Unknown calibrator kind \"%s\"", e.Kind)
+	}
+}
+
+func encodeClassifier(c Classifier) (*envelope, error) {
+	switch c := c.(type) {
+	case *AdaBoost:
+		saved := &savedAdaBoost{nil, c.A}
+		for _, h := range c.H {
+			e, err := encodeClassifier(h)
+			if err != nil {
+				return nil, err
+			}
+			saved.H = append(saved.H, e)
+		}
+		return newEnvelope("adaboost", saved)
+	case *FeatureNode:
+		f, err := encodeFeature(c.feature)
+		if err != nil {
+			return nil, err
+		}
+		pos, err := encodeClassifier(c.positive)
+		if err != nil {
+			return nil, err
+		}
+		neg, err := encodeClassifier(c.negative)
+		if err != nil {
+			return nil, err
+		}
+		return newEnvelope("feature-node", &savedFeatureNode{f, pos, neg})
+	case *ThresholdNode:
+		f, err := encodeFeature(c.feature)
+		if err != nil {
+			return nil, err
+		}
+		below, err := encodeClassifier(c.below)
+		if err != nil {
+			return nil, err
+		}
+		above, err := encodeClassifier(c.above)
+		if err != nil {
+			return nil, err
+		}
+		return newEnvelope("threshold-node", &savedThresholdNode{f, c.threshold, below, above, c.pAbove})
+	case *CategoryNode:
+		f, err := encodeCategoricalFeature(c.feature)
+		if err != nil {
+			return nil, err
+		}
+		saved := &savedCategoryNode{f, c.categories, nil, c.weights}
+		for _, child := range c.children {
+			e, err := encodeClassifier(child)
+			if err != nil {
+				return nil, err
+			}
+			saved.Children = append(saved.Children, e)
+		}
+		return newEnvelope("category-node", saved)
+	case *LeafNode:
+		return newEnvelope("leaf", &savedLeafNode{c.class})
+	case *CalibratedClassifier:
+		classifier, err := encodeClassifier(c.Classifier)
+		if err != nil {
+			return nil, err
+		}
+		calibrator, err := encodeCalibrator(c.Calibrator)
+		if err != nil {
+			return nil, err
+		}
+		return newEnvelope("calibrated", &savedCalibratedClassifier{classifier, calibrator})
+	case Feature:
+		// Features, such as the stumps built by DecisionStumper, are
+		// classifiers in their own right.
+		f, err := encodeFeature(c)
+		if err != nil {
+			return nil, err
+		}
+		return newEnvelope("feature", f)
+	default:
+		return nil, fmt.Errorf("Classifier of type %T can not be saved", c)
+	}
+}
+
+func decodeClassifier(e *envelope) (Classifier, error) {
+	if e == nil {
+		return nil, fmt.Errorf("Missing classifier")
+	}
+	switch e.Kind {
+	case "adaboost":
+		var saved savedAdaBoost
+		if err := json.Unmarshal(e.Data, &saved); err != nil {
+			return nil, err
+		}
+		if len(saved.H) != len(saved.A) {
+			return nil, fmt.Errorf("AdaBoost has %d classifiers but %d weights", len(saved.H), len(saved.A))
+		}
+		a := &AdaBoost{A: saved.A}
+		for _, h := range saved.H {
+			c, err := decodeClassifier(h)
+			if err != nil {
+				return nil, err
+			}
+			a.H = append(a.H, c)
+		}
+		return a, nil
+	case "feature-node":
+		var saved savedFeatureNode
+		if err := json.Unmarshal(e.Data, &saved); err != nil {
+			return nil, err
+		}
+		f, err := decodeFeature(saved.Feature)
+		if err != nil {
+			return nil, err
+		}
+		pos, err := decodeClassifier(saved.Positive)
+		if err != nil {
+			return nil, err
+		}
+		neg, err := decodeClassifier(saved.Negative)
+		if err != nil {
+			return nil, err
+		}
+		return &FeatureNode{f, pos, neg}, nil
+	case "threshold-node":
+		var saved savedThresholdNode
+		if err := json.Unmarshal(e.Data, &saved); err != nil {
+			return nil, err
+		}
+		f, err := decodeFeature(saved.Feature)
+		if err != nil {
+			return nil, err
+		}
+		below, err := decodeClassifier(saved.Below)
+		if err != nil {
+			return nil, err
+		}
+		above, err := decodeClassifier(saved.Above)
+		if err != nil {
+			return nil, err
+		}
+		return &ThresholdNode{f, saved.Threshold, below, above, saved.PAbove}, nil
+	case "category-node":
+		var saved savedCategoryNode
+		if err := json.Unmarshal(e.Data, &saved); err != nil {
+			return nil, err
+		}
+		if len(saved.Children) != len(saved.Categories) || len(saved.Weights) != len(saved.Categories) {
+			return nil, fmt.Errorf("Category node has %d categories but %d children and %d weights", len(saved.Categories), len(saved.Children), len(saved.Weights))
+		}
+		f, err := decodeCategoricalFeature(saved.Feature)
+		if err != nil {
+			return nil, err
+		}
+		n := &CategoryNode{f, saved.Categories, nil, saved.Weights}
+		for _, child := range saved.Children {
+			c, err := decodeClassifier(child)
+			if err != nil {
+				return nil, err
+			}
+			n.children = append(n.children, c)
+		}
+		return n, nil
+	case "leaf":
+		var saved savedLeafNode
+		if err := json.Unmarshal(e.Data, &saved); err != nil {
+			return nil, err
+		}
+		return &LeafNode{saved.Class}, nil
+	case "calibrated":
+		var saved savedCalibratedClassifier
+		if err := json.Unmarshal(e.Data, &saved); err != nil {
+			return nil, err
+		}
+		classifier, err := decodeClassifier(saved.Classifier)
+		if err != nil {
+			return nil, err
+		}
+		calibrator, err := decodeCalibrator(saved.Calibrator)
+		if err != nil {
+			return nil, err
+		}
+		return &CalibratedClassifier{classifier, calibrator}, nil
+	case "feature":
+		var saved envelope
+		if err := json.Unmarshal(e.Data, &saved); err != nil {
+			return nil, err
+		}
+		return decodeFeature(&saved)
+	default:
+		return nil, fmt.Errorf("Unknown classifier kind \"%s\"", e.Kind)
+	}
+}
+
+// SaveModel writes c, which is typically an *AdaBoost, to w. Every
+// feature in the model must have been registered with
+// RegisterFeature or RegisterCategoricalFeature.
+func SaveModel(w io.Writer, c Classifier) error {
+	return SaveModelWithSettings(w, c, nil)
+}
+
+// SaveModelWithSettings writes c to w like SaveModel, along with
+// settings, which LoadModelSettings reads back.
+func SaveModelWithSettings(w io.Writer, c Classifier, settings map[string]string) error {
+	e, err := encodeClassifier(c)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(&savedModel{modelFormatVersion, e, settings})
+}
+
+// readSavedModel reads a saved model without decoding its classifier.
+func readSavedModel(r io.Reader) (*savedModel, error) {
+	var saved savedModel
+	if err := json.NewDecoder(r).Decode(&saved); err != nil {
+		return nil, err
+	}
+	if saved.Version != modelFormatVersion {
+		return nil, fmt.Errorf("Model has format version %d but only version %d is supported", saved.Version, modelFormatVersion)
+	}
+	return &saved, nil
+}
+
+// LoadModel reads a model written by SaveModel.
+func LoadModel(r io.Reader) (Classifier, error) {
+	saved, err := readSavedModel(r)
+	if err != nil {
+		return nil, err
+	}
+	return decodeClassifier(saved.Model)
+}
+
+// LoadModelSettings reads the settings a model was saved with, or nil
+// if it was saved without any.
+func LoadModelSettings(r io.Reader) (map[string]string, error) {
+	saved, err := readSavedModel(r)
+	if err != nil {
+		return nil, err
+	}
+	return saved.Settings, nil
+}
+
+// LoadAdaBoost reads an AdaBoost model written by SaveModel. The
+// result can predict straight away; call Resume before running more
+// rounds. If the model was saved calibrated, the calibration is
+// dropped, because it does not fit the model after more rounds.
+func LoadAdaBoost(r io.Reader) (*AdaBoost, error) {
+	c, err := LoadModel(r)
+	if err != nil {
+		return nil, err
+	}
+	if calibrated, ok := c.(*CalibratedClassifier); ok {
+		c = calibrated.Classifier
+	}
+	a, ok := c.(*AdaBoost)
+	if !ok {
+		return nil, fmt.Errorf("Model is a %T, not AdaBoost", c)
+	}
+	return a, nil
+}
+
+// Resume prepares a loaded model for further boosting rounds over
+// examples es. The distribution is recovered from the margins of the
+// existing ensemble, so it matches the one the model was trained with
+// when es are the original training examples. Set Initial and Costs
+// first if the model was trained with them.
+func (a *AdaBoost) Resume(es []Example, learner Learner, r *rand.Rand) {
+	a.Examples = es
+	a.Learner = learner
+	a.rand = r
+	a.recomputeDistribution()
+}
+
+// recomputeDistribution sets D to the distribution boosting the
+// rounds in H over the examples would have produced, starting from
+// Initial and with Costs.
+func (a *AdaBoost) recomputeDistribution() {
+	a.D = UniformDistribution(len(a.Examples))
+
+	// Shift the exponents by the largest one so that they can not
+	// overflow.
+	maxExponent := math.Inf(-1)
+	for i, example := range a.Examples {
+		a.D.P[i] = -float64OfLabel(example.Label())*a.Predict(example) + float64(len(a.H))*a.logCost(i)
+		if a.Initial != nil {
+			a.D.P[i] += math.Log(a.Initial.P[i])
+		}
+		maxExponent = math.Max(maxExponent, a.D.P[i])
+	}
+	for i := range a.D.P {
+		a.D.P[i] = math.Exp(a.D.P[i] - maxExponent)
+	}
+	a.D.normalize(a.Parallelism)
+}
@@ -0,0 +1,101 @@
+package ml
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func init() {
+	RegisterFeature("reflected", &reflectedFeature{}, func(f Feature) (interface{}, error) {
+		return []string{f.(*reflectedFeature).name, f.(*reflectedFeature).value}, nil
+	}, func(data json.RawMessage) (Feature, error) {
+		var fields []string
+		err := json.Unmarshal(data, &fields)
+		if err != nil {
+			return nil, err
+		}
+		return &reflectedFeature{fields[0], fields[1]}, nil
+	})
+}
+
+func TestSaveAndLoadAdaBoost(t *testing.T) {
+	dataset := []Example{
+		&datum{"red", "heavy", true},
+		&datum{"red", "light", false},
+		&datum{"yellow", "light", false},
+		&datum{"yellow", "heavy", true},
+		&datum{"yellow", "light", true},
+	}
+	features := []Feature{
+		&reflectedFeature{"Color", "red"},
+		&reflectedFeature{"Weight", "heavy"},
+		&andFeature{&reflectedFeature{"Color", "yellow"}, &FeatureNegater{&reflectedFeature{"Weight", "heavy"}}},
+	}
+
+	r := rand.New(rand.NewSource(42))
+	booster := NewAdaBoost(dataset, NewDecisionTreeBuilder(features, 3), r)
+	for i := 0; i < 3; i++ {
+		booster.Round(len(dataset))
+	}
+
+	var buf bytes.Buffer
+	if err := SaveModel(&buf, booster); err != nil {
+		t.Fatalf("should have saved the model: %v", err)
+	}
+	loaded, err := LoadAdaBoost(&buf)
+	if err != nil {
+		t.Fatalf("should have loaded the model: %v", err)
+	}
+	if len(loaded.H) != len(booster.H) {
+		t.Errorf("expected %d classifiers but was %d", len(booster.H), len(loaded.H))
+	}
+	for _, example := range dataset {
+		if expected, actual := booster.Predict(example), loaded.Predict(example); expected != actual {
+			t.Errorf("expected loaded model to predict %f for %v but was %f", expected, example, actual)
+		}
+	}
+
+	loaded.Resume(dataset, NewDecisionTreeBuilder(features, 3), r)
+	for i, p := range booster.D.P {
+		if diff := p - loaded.D.P[i]; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("expected resumed weight %d to be %f but was %f", i, p, loaded.D.P[i])
+		}
+	}
+}
+
+func TestLoadModelUnknownFeature(t *testing.T) {
+	doc := `{"version":2,"model":{"kind":"feature","data":{"kind":"mystery","data":"x"}}}`
+	_, err := LoadModel(strings.NewReader(doc))
+	if err == nil || !strings.Contains(err.Error(), "mystery") {
+		t.Errorf("expected an error about the unknown feature kind but was %v", err)
+	}
+}
+
+func TestSaveModelWithSettings(t *testing.T) {
+	settings := map[string]string{"tokenizer": "words", "stem": "true"}
+	var buf bytes.Buffer
+	if err := SaveModelWithSettings(&buf, &LeafNode{true}, settings); err != nil {
+		t.Fatalf("should have saved the model: %v", err)
+	}
+	saved := buf.String()
+	loaded, err := LoadModelSettings(strings.NewReader(saved))
+	if err != nil {
+		t.Fatalf("should have loaded the settings: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, settings) {
+		t.Errorf("expected settings %v but was %v", settings, loaded)
+	}
+	if _, err := LoadModel(strings.NewReader(saved)); err != nil {
+		t.Errorf("should have loaded the model: %v", err)
+	}
+
+	// Models from before settings were saved are refused.
+	doc := `{"version":1,"model":{"kind":"leaf","data":true}}`
+	if _, err := LoadModelSettings(strings.NewReader(doc)); err == nil {
+		t.Errorf("expected a model of version 1 to be refused")
+	}
+}
@@ -0,0 +1,317 @@
+package ml
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+)
+
+// treeChildren returns the children of a decision tree node, or nil
+// if c is a leaf or not a tree node.
+func treeChildren(c Classifier) []Classifier {
+	switch n := c.(type) {
+	case *FeatureNode:
+		return []Classifier{n.positive, n.negative}
+	case *ThresholdNode:
+		return []Classifier{n.below, n.above}
+	case *CategoryNode:
+		return n.children
+	default:
+		return nil
+	}
+}
+
+// withTreeChildren returns a copy of the tree node c with different
+// children, in the order treeChildren returns them.
+func withTreeChildren(c Classifier, children []Classifier) Classifier {
+	switch n := c.(type) {
+	case *FeatureNode:
+		return &FeatureNode{n.feature, children[0], children[1]}
+	case *ThresholdNode:
+		return &ThresholdNode{n.feature, n.threshold, children[0], children[1], n.pAbove}
+	case *CategoryNode:
+		return &CategoryNode{n.feature, n.categories, children, n.weights}
+	default:
+		panic(fmt.Sprintf("ml: %T is not a tree node", c))
+	}
+}
+
+// treeBranch returns which of the children of the tree node c the
+// example goes down. Like the tree builder, it sends examples with a
+// missing value down the branch which had the most training examples.
+func treeBranch(c Classifier, e Example) int {
+	switch n := c.(type) {
+	case *FeatureNode:
+		if math.Signbit(n.feature.Predict(e)) {
+			return 1
+		}
+		return 0
+	case *ThresholdNode:
+		v := n.feature.Predict(e)
+		if v > n.threshold || (math.IsNaN(v) && n.pAbove > 0.5) {
+			return 1
+		}
+		return 0
+	case *CategoryNode:
+		category := n.feature.Category(e)
+		for i, c := range n.categories {
+			if c == category {
+				return i
+			}
+		}
+		largest := 0
+		for i, w := range n.weights {
+			if w > n.weights[largest] {
+				largest = i
+			}
+		}
+		return largest
+	default:
+		panic(fmt.Sprintf("ml: %T is not a tree node", c))
+	}
+}
+
+// partitionExamples splits the examples by the branch of c they go
+// down.
+func partitionExamples(c Classifier, nchildren int, examples []Example) [][]Example {
+	parts := make([][]Example, nchildren)
+	for _, e := range examples {
+		i := treeBranch(c, e)
+		parts[i] = append(parts[i], e)
+	}
+	return parts
+}
+
+// treeSize returns the number of nodes in a tree.
+func treeSize(c Classifier) int {
+	n := 1
+	for _, child := range treeChildren(c) {
+		n += treeSize(child)
+	}
+	return n
+}
+
+// majorityLeaf returns a leaf which predicts the more common class
+// of the examples, and how many of them it misclassifies. Ties are
+// negative, like the leaves the tree builder makes at maximum depth.
+func majorityLeaf(examples []Example) (*LeafNode, int) {
+	npos := 0
+	for _, e := range examples {
+		if e.Label() {
+			npos++
+		}
+	}
+	if npos > len(examples)-npos {
+		return &LeafNode{true}, len(examples) - npos
+	}
+	return &LeafNode{false}, npos
+}
+
+func leafErrors(n *LeafNode, examples []Example) int {
+	errors := 0
+	for _, e := range examples {
+		if bool(e.Label()) != n.class {
+			errors++
+		}
+	}
+	return errors
+}
+
+// PruneReducedError prunes a decision tree bottom up, replacing each
+// subtree with a leaf which predicts the majority class of the
+// training examples that reach it, unless the subtree makes fewer
+// errors on the validation examples. The tree is not modified. It
+// returns the pruned tree and the number of nodes removed.
+func PruneReducedError(tree Classifier, train []Example, validation []Example) (Classifier, int) {
+	pruned, removed, _ := pruneReducedError(tree, train, validation)
+	return pruned, removed
+}
+
+// pruneReducedError also returns the number of validation errors the
+// pruned tree makes.
+func pruneReducedError(c Classifier, train []Example, validation []Example) (Classifier, int, int) {
+	children := treeChildren(c)
+	if children == nil {
+		if leaf, ok := c.(*LeafNode); ok {
+			return leaf, 0, leafErrors(leaf, validation)
+		}
+		return c, 0, evaluateErrors(c, validation)
+	}
+
+	trainParts := partitionExamples(c, len(children), train)
+	validationParts := partitionExamples(c, len(children), validation)
+	prunedChildren := make([]Classifier, len(children))
+	removed, subtreeErrors := 0, 0
+	for i, child := range children {
+		var r, e int
+		prunedChildren[i], r, e = pruneReducedError(child, trainParts[i], validationParts[i])
+		removed += r
+		subtreeErrors += e
+	}
+
+	leaf, _ := majorityLeaf(train)
+	if errors := leafErrors(leaf, validation); errors <= subtreeErrors {
+		return leaf, treeSize(c) - 1, errors
+	}
+	return withTreeChildren(c, prunedChildren), removed, subtreeErrors
+}
+
+func evaluateErrors(c Classifier, examples []Example) int {
+	errors := 0
+	for _, e := range examples {
+		if !math.Signbit(c.Predict(e)) != bool(e.Label()) {
+			errors++
+		}
+	}
+	return errors
+}
+
+// PrunePessimistic prunes a decision tree the way C4.5 does, without
+// a validation set. The errors a leaf would make on unseen examples
+// are estimated by the upper limit of a confidence interval around
+// its training error rate; a subtree is replaced with a leaf unless
+// its leaves' estimated errors are lower. Lower confidence prunes
+// more; C4.5 uses 0.25. The tree is not modified. It returns the
+// pruned tree and the number of nodes removed.
+func PrunePessimistic(tree Classifier, train []Example, confidence float64) (Classifier, int) {
+	pruned, removed, _ := prunePessimistic(tree, train, confidence)
+	return pruned, removed
+}
+
+// prunePessimistic also returns the estimated errors of the pruned
+// tree.
+func prunePessimistic(c Classifier, train []Example, confidence float64) (Classifier, int, float64) {
+	children := treeChildren(c)
+	if children == nil {
+		errors := evaluateErrors(c, train)
+		return c, 0, float64(errors) + pessimisticErrors(len(train), errors, confidence)
+	}
+
+	parts := partitionExamples(c, len(children), train)
+	prunedChildren := make([]Classifier, len(children))
+	removed, subtreeErrors := 0, 0.0
+	for i, child := range children {
+		var r int
+		var e float64
+		prunedChildren[i], r, e = prunePessimistic(child, parts[i], confidence)
+		removed += r
+		subtreeErrors += e
+	}
+
+	// C4.5 prefers the leaf unless the subtree is better by at least
+	// 0.1 of an error.
+	leaf, errors := majorityLeaf(train)
+	if leafErrors := float64(errors) + pessimisticErrors(len(train), errors, confidence); leafErrors <= subtreeErrors+0.1 {
+		return leaf, treeSize(c) - 1, leafErrors
+	}
+	return withTreeChildren(c, prunedChildren), removed, subtreeErrors
+}
+
+// pessimisticErrors returns how many more errors than e, out of n
+// examples, a leaf could make at the upper limit of the confidence
+// interval. This is AddErrs from C4.5, which uses the normal
+// approximation to the binomial distribution.
+func pessimisticErrors(n int, e int, confidence float64) float64 {
+	if n == 0 {
+		return 0.0
+	}
+	N, E := float64(n), float64(e)
+	if e == 0 {
+		return N * (1.0 - math.Pow(confidence, 1.0/N))
+	}
+	if E+0.5 >= N {
+		return 0.67 * (N - E)
+	}
+	z := math.Sqrt2 * math.Erfinv(1.0-2.0*confidence)
+	coeff := z * z
+	pr := (E + 0.5 + coeff/2.0 + math.Sqrt(coeff*((E+0.5)*(1.0-(E+0.5)/N)+coeff/4.0))) / (N + coeff)
+	return N*pr - E
+}
+
+type PruningMethod int
+
+const (
+	NoPruning PruningMethod = iota
+	// ReducedErrorPruning holds out some of the examples to prune with.
+	ReducedErrorPruning
+	// PessimisticPruning prunes with the examples the tree was built
+	// from.
+	PessimisticPruning
+)
+
+// PrunedTreeBuilder is a Learner which builds decision trees and
+// prunes them, so it can be used as AdaBoost's weak learner.
+type PrunedTreeBuilder struct {
+	Builder *DecisionTreeBuilder
+	Method  PruningMethod
+	// HoldOut is the fraction of the examples reduced-error pruning
+	// holds out from building the tree, to prune it with.
+	HoldOut float64
+	// Confidence is the confidence level of pessimistic pruning.
+	Confidence float64
+	// Counted atomically, because a builder may be shared between
+	// goroutines.
+	trees   int64
+	removed int64
+}
+
+func NewPrunedTreeBuilder(tb *DecisionTreeBuilder, method PruningMethod) *PrunedTreeBuilder {
+	return &PrunedTreeBuilder{tb, method, 1.0 / 3.0, 0.25, 0, 0}
+}
+
+// Pruned returns how many trees have been built and how many nodes
+// pruning removed from them in total.
+func (b *PrunedTreeBuilder) Pruned() (int, int) {
+	return int(atomic.LoadInt64(&b.trees)), int(atomic.LoadInt64(&b.removed))
+}
+
+// holdOut returns the indices of the examples to build a tree from
+// and to prune it with. AdaBoost samples examples with replacement,
+// so the same example may appear several times; every copy goes to
+// the same side, so that the tree is never pruned with the examples
+// it was built from. The held out examples are spread evenly through
+// the distinct examples, in the random order AdaBoost samples them.
+func (b *PrunedTreeBuilder) holdOut(examples []Example) ([]int, []int) {
+	var grow, prune []int
+	heldOut := make(map[Example]bool)
+	for i, e := range examples {
+		held, seen := heldOut[e]
+		if !seen {
+			n := float64(len(heldOut))
+			held = math.Floor((n+1.0)*b.HoldOut) > math.Floor(n*b.HoldOut)
+			heldOut[e] = held
+		}
+		if held {
+			prune = append(prune, i)
+		} else {
+			grow = append(grow, i)
+		}
+	}
+	return grow, prune
+}
+
+func (b *PrunedTreeBuilder) NewClassifier(examples []Example) Classifier {
+	return b.NewClassifierFromMatrix(NewFeatureMatrix(b.Builder.features, examples))
+}
+
+func (b *PrunedTreeBuilder) NewClassifierFromMatrix(m *FeatureMatrix) Classifier {
+	var tree Classifier
+	var removed int
+	switch b.Method {
+	case ReducedErrorPruning:
+		grow, prune := b.holdOut(m.Examples)
+		growMatrix := m.Rows(grow)
+		validation := make([]Example, len(prune))
+		for j, i := range prune {
+			validation[j] = m.Examples[i]
+		}
+		tree, removed = PruneReducedError(b.Builder.NewClassifierFromMatrix(growMatrix), growMatrix.Examples, validation)
+	case PessimisticPruning:
+		tree, removed = PrunePessimistic(b.Builder.NewClassifierFromMatrix(m), m.Examples, b.Confidence)
+	default:
+		tree = b.Builder.NewClassifierFromMatrix(m)
+	}
+	atomic.AddInt64(&b.trees, 1)
+	atomic.AddInt64(&b.removed, int64(removed))
+	return tree
+}
@@ -0,0 +1,128 @@
+package ml
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func bitsOf(class bool, bs ...bool) *bitsDatum {
+	return &bitsDatum{bs, Label(class)}
+}
+
+// noisyTree splits on bit 0, then on bit 1, which is noise.
+func noisyTree() Classifier {
+	return &FeatureNode{bitFeature(0), &FeatureNode{bitFeature(1), &LeafNode{true}, &LeafNode{false}}, &LeafNode{false}}
+}
+
+func TestPruneReducedError(t *testing.T) {
+	train := []Example{
+		bitsOf(true, true, true),
+		bitsOf(true, true, true),
+		bitsOf(false, true, false),
+		bitsOf(false, false, true),
+		bitsOf(false, false, false),
+	}
+	validation := []Example{
+		bitsOf(true, true, true),
+		bitsOf(true, true, false),
+		bitsOf(false, false, true),
+	}
+	tree := noisyTree()
+	pruned, removed := PruneReducedError(tree, train, validation)
+	if removed != 2 {
+		t.Errorf("expected 2 nodes to be removed but was %d", removed)
+	}
+	if n := treeSize(pruned); n != 3 {
+		t.Errorf("expected the pruned tree to have 3 nodes but was %d", n)
+	}
+	if n := treeSize(tree); n != 5 {
+		t.Errorf("expected the original tree to be unchanged but had %d nodes", n)
+	}
+	for i, example := range validation {
+		if Label(pruned.Predict(example) > 0.0) != example.Label() {
+			t.Errorf("expected pruned tree to classify example %d as %v", i, example.Label())
+		}
+	}
+}
+
+func TestPrunePessimistic(t *testing.T) {
+	// Bit 1 separates one example from nine, and gets it wrong.
+	var train []Example
+	for i := 0; i < 8; i++ {
+		train = append(train, bitsOf(true, true, true))
+	}
+	train = append(train, bitsOf(false, true, true), bitsOf(true, true, false), bitsOf(false, false, false))
+	pruned, removed := PrunePessimistic(noisyTree(), train, 0.25)
+	if removed != 2 {
+		t.Errorf("expected 2 nodes to be removed but was %d", removed)
+	}
+	if _, ok := pruned.(*FeatureNode).positive.(*LeafNode); !ok {
+		t.Errorf("expected the split on bit 1 to be pruned")
+	}
+}
+
+func TestPessimisticErrors(t *testing.T) {
+	// From Quinlan, "C4.5: Programs for Machine Learning", p. 41.
+	if e := pessimisticErrors(6, 0, 0.25); math.Abs(e-6*0.206) > 0.01 {
+		t.Errorf("expected about %f errors but was %f", 6*0.206, e)
+	}
+	if e1, e2 := pessimisticErrors(100, 10, 0.25), pessimisticErrors(100, 10, 0.1); e2 <= e1 {
+		t.Errorf("expected lower confidence to be more pessimistic but was %f and %f", e1, e2)
+	}
+}
+
+func TestPrunedTreeBuilderInAdaBoost(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	features := []Feature{bitFeature(0), bitFeature(1), bitFeature(2)}
+	var dataset []Example
+	for i := 0; i < 300; i++ {
+		d := bitsOf(false, r.Intn(2) == 0, r.Intn(2) == 0, r.Intn(2) == 0)
+		d.class = Label(d.bits[0] != (r.Intn(10) == 0))
+		dataset = append(dataset, d)
+	}
+	for _, method := range []PruningMethod{ReducedErrorPruning, PessimisticPruning} {
+		learner := NewPrunedTreeBuilder(NewDecisionTreeBuilder(features, 4), method)
+		booster := NewAdaBoost(dataset, learner, rand.New(rand.NewSource(42)))
+		for i := 0; i < 3; i++ {
+			booster.Round(len(dataset))
+		}
+		trees, removed := learner.Pruned()
+		if trees != 3 {
+			t.Errorf("expected 3 trees to be built but was %d", trees)
+		}
+		if removed == 0 {
+			t.Errorf("expected pruning method %d to remove nodes from trees fit to noise", method)
+		}
+		if e := booster.Evaluate(dataset); e > 0.15 {
+			t.Errorf("expected error of about 0.1 but was %f", e)
+		}
+	}
+}
+
+func TestHoldOutKeepsCopiesTogether(t *testing.T) {
+	var distinct []Example
+	for i := 0; i < 20; i++ {
+		distinct = append(distinct, bitsOf(i%2 == 0, i%3 == 0))
+	}
+	// A sample with replacement, as AdaBoost draws.
+	r := rand.New(rand.NewSource(42))
+	sample := make([]Example, 60)
+	for i := range sample {
+		sample[i] = distinct[r.Intn(len(distinct))]
+	}
+	b := NewPrunedTreeBuilder(NewDecisionTreeBuilder(nil, 3), ReducedErrorPruning)
+	grow, prune := b.holdOut(sample)
+	if len(grow)+len(prune) != len(sample) || len(prune) == 0 || len(grow) == 0 {
+		t.Fatalf("expected the sample to be split in two but was %d and %d", len(grow), len(prune))
+	}
+	grown := make(map[Example]bool)
+	for _, i := range grow {
+		grown[sample[i]] = true
+	}
+	for _, i := range prune {
+		if grown[sample[i]] {
+			t.Fatalf("expected no example to be both grown and pruned with, but %d was", i)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package ml
+
+// RelabeledExample gives an example a different label. It lets one
+// set of examples, and the features extracted from them, be shared
+// by several binary models which each predict a different label.
+type RelabeledExample struct {
+	Example
+	label Label
+}
+
+func (e *RelabeledExample) Label() Label {
+	return e.label
+}
+
+// Relabel returns the examples labelled by the label function.
+func Relabel(es []Example, label func(Example) Label) []Example {
+	relabeled := make([]Example, len(es))
+	for i, e := range es {
+		relabeled[i] = &RelabeledExample{e, label(e)}
+	}
+	return relabeled
+}
+
+// Unwrap returns the example a RelabeledExample was made from. Other
+// examples are returned as-is. Features which need the concrete type
+// of their examples should unwrap them first.
+func Unwrap(e Example) Example {
+	for {
+		r, ok := e.(*RelabeledExample)
+		if !ok {
+			return e
+		}
+		e = r.Example
+	}
+}
@@ -0,0 +1,193 @@
+package ml
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Param is a hyperparameter to search over. Grid search tries each of
+// Values. Random search draws from [Min, Max] if Min < Max,
+// uniformly or, if Log, log-uniformly, rounding to an integer if
+// Integer; otherwise it draws from Values.
+type Param struct {
+	Name    string
+	Values  []float64
+	Min     float64
+	Max     float64
+	Log     bool
+	Integer bool
+}
+
+// Config gives each hyperparameter of a trial a value, by name.
+type Config map[string]float64
+
+// Grid returns every combination of the params' Values, varying the
+// last param fastest. Params without Values can not be searched by
+// grid.
+func Grid(params []Param) ([]Config, error) {
+	configs := []Config{Config{}}
+	for _, p := range params {
+		if len(p.Values) == 0 {
+			return nil, fmt.Errorf("Parameter \"%s\" has no values to search", p.Name)
+		}
+		var next []Config
+		for _, c := range configs {
+			for _, v := range p.Values {
+				config := Config{p.Name: v}
+				for name, value := range c {
+					config[name] = value
+				}
+				next = append(next, config)
+			}
+		}
+		configs = next
+	}
+	return configs, nil
+}
+
+// sample draws a value of p for random search.
+func (p *Param) sample(r *rand.Rand) float64 {
+	if p.Min >= p.Max {
+		return p.Values[r.Intn(len(p.Values))]
+	}
+	var v float64
+	if p.Log {
+		v = math.Exp(math.Log(p.Min) + r.Float64()*(math.Log(p.Max)-math.Log(p.Min)))
+	} else {
+		v = p.Min + r.Float64()*(p.Max-p.Min)
+	}
+	if p.Integer {
+		v = math.Round(v)
+	}
+	return v
+}
+
+// RandomConfigs draws n configs, each param independently, as in
+// Bergstra and Bengio, "Random Search for Hyper-Parameter
+// Optimization", 2012. Params with Log ranges must have positive Min
+// and Max.
+func RandomConfigs(params []Param, n int, r *rand.Rand) ([]Config, error) {
+	for _, p := range params {
+		if p.Min >= p.Max && len(p.Values) == 0 {
+			return nil, fmt.Errorf("Parameter \"%s\" has no range or values to search", p.Name)
+		} else if p.Min < p.Max && p.Log && p.Min <= 0.0 {
+			return nil, fmt.Errorf("Parameter \"%s\" has a log range [%g, %g] which is not positive", p.Name, p.Min, p.Max)
+		}
+	}
+	configs := make([]Config, n)
+	for i := range configs {
+		configs[i] = Config{}
+		for j := range params {
+			configs[i][params[j].Name] = params[j].sample(r)
+		}
+	}
+	return configs, nil
+}
+
+// Names returns the names of the config's params in alphabetical
+// order.
+func (c Config) Names() []string {
+	var names []string
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Trial is the cross-validated result of one config.
+type Trial struct {
+	Config     Config           `json:"config"`
+	Validation *CrossValidation `json:"validation"`
+}
+
+// SearchResult holds the trials of a search in the order of their
+// configs.
+type SearchResult struct {
+	// Metric is the mean cross-validated metric, one of
+	// EvaluationMetrics, which chooses the best trial.
+	Metric string  `json:"metric"`
+	Trials []Trial `json:"trials"`
+	Best   int     `json:"best"`
+}
+
+// Search cross-validates each config with evaluate, running up to
+// parallelism trials at once, and chooses the one with the best mean
+// metric. Ties go to the earliest config, so the result does not
+// depend on parallelism as long as evaluate does not. It returns an
+// error, before running any trials, if metric is not one of
+// EvaluationMetrics.
+func Search(configs []Config, metric string, parallelism int, evaluate func(trial int, config Config) *CrossValidation) (*SearchResult, error) {
+	if !IsEvaluationMetric(metric) {
+		return nil, fmt.Errorf("Unknown metric \"%s\"", metric)
+	}
+	s := &SearchResult{metric, make([]Trial, len(configs)), -1}
+	parallelBlocks(len(configs), 1, parallelism, func(block int, start int, end int) {
+		for i := start; i < end; i++ {
+			s.Trials[i] = Trial{configs[i], evaluate(i, configs[i])}
+		}
+	})
+	for i, t := range s.Trials {
+		value := t.Validation.Metrics[metric].Mean
+		if s.Best == -1 || metricImproves(metric, value, s.Trials[s.Best].Validation.Metrics[metric].Mean) {
+			s.Best = i
+		}
+	}
+	return s, nil
+}
+
+// BestConfig returns the config of the best trial, or nil if there
+// were no trials.
+func (s *SearchResult) BestConfig() Config {
+	if s.Best == -1 {
+		return nil
+	}
+	return s.Trials[s.Best].Config
+}
+
+// WriteCSV writes a row per trial: the trial's number, its params in
+// alphabetical order, then the mean and standard deviation of each of
+// EvaluationMetrics.
+func (s *SearchResult) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	var names []string
+	if len(s.Trials) > 0 {
+		names = s.Trials[0].Config.Names()
+	}
+	header := append([]string{"trial"}, names...)
+	for _, metric := range EvaluationMetrics {
+		header = append(header, metric, metric+"_std")
+	}
+	cw.Write(header)
+	for i, t := range s.Trials {
+		row := []string{fmt.Sprint(i)}
+		for _, name := range names {
+			row = append(row, formatFloat(t.Config[name]))
+		}
+		for _, metric := range EvaluationMetrics {
+			m := t.Validation.Metrics[metric]
+			row = append(row, formatFloat(m.Mean), formatFloat(math.Sqrt(m.Variance)))
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteBestJSON writes the best trial, with its config and the
+// evaluation of each fold, as JSON.
+func (s *SearchResult) WriteBestJSON(w io.Writer) error {
+	if s.Best == -1 {
+		return fmt.Errorf("No trials to choose from")
+	}
+	return json.NewEncoder(w).Encode(&struct {
+		Metric string `json:"metric"`
+		Number int    `json:"trial"`
+		*Trial
+	}{s.Metric, s.Best, &s.Trials[s.Best]})
+}
@@ -0,0 +1,121 @@
+package ml
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestGrid(t *testing.T) {
+	configs, err := Grid([]Param{
+		{Name: "depth", Values: []float64{2, 3}},
+		{Name: "rate", Values: []float64{0.1, 0.2, 0.3}},
+	})
+	if err != nil {
+		t.Fatalf("should have made the grid: %v", err)
+	}
+	if len(configs) != 6 {
+		t.Fatalf("expected 6 configs but was %d", len(configs))
+	}
+	if c := configs[1]; c["depth"] != 2 || c["rate"] != 0.2 {
+		t.Errorf("expected the last param to vary fastest but config 1 was %v", c)
+	}
+	if _, err := Grid([]Param{{Name: "rate", Min: 0.1, Max: 0.3}}); err == nil {
+		t.Errorf("expected a range to be refused for grid search")
+	}
+}
+
+func TestRandomConfigs(t *testing.T) {
+	params := []Param{
+		{Name: "depth", Min: 2, Max: 6, Integer: true},
+		{Name: "l2", Min: 1e-6, Max: 1e-2, Log: true},
+		{Name: "alpha", Values: []float64{0.5, 1.0}},
+	}
+	configs, err := RandomConfigs(params, 100, rand.New(rand.NewSource(42)))
+	if err != nil {
+		t.Fatalf("should have drawn configs: %v", err)
+	}
+	small := 0
+	for _, c := range configs {
+		if d := c["depth"]; d < 2 || d > 6 || d != float64(int(d)) {
+			t.Errorf("expected an integer depth from 2 to 6 but was %f", d)
+		}
+		if l2 := c["l2"]; l2 < 1e-6 || l2 > 1e-2 {
+			t.Errorf("expected l2 from 1e-6 to 1e-2 but was %g", l2)
+		} else if l2 < 1e-4 {
+			small++
+		}
+		if a := c["alpha"]; a != 0.5 && a != 1.0 {
+			t.Errorf("expected alpha to be one of its values but was %f", a)
+		}
+	}
+	// Half of a log-uniform range is below its geometric mean.
+	if small < 30 || small > 70 {
+		t.Errorf("expected about half of l2 to be below 1e-4 but %d were", small)
+	}
+	if _, err := RandomConfigs([]Param{{Name: "l2", Min: 0, Max: 1, Log: true}}, 1, rand.New(rand.NewSource(42))); err == nil {
+		t.Errorf("expected a log range from 0 to be refused")
+	}
+	if _, err := RandomConfigs([]Param{{Name: "l2"}}, 1, rand.New(rand.NewSource(42))); err == nil {
+		t.Errorf("expected a param without a range or values to be refused")
+	}
+}
+
+func TestSearch(t *testing.T) {
+	dataset := []Example{
+		&scoredDatum{2.0, true},
+		&scoredDatum{1.0, true},
+		&scoredDatum{0.5, false},
+		&scoredDatum{-1.0, false},
+	}
+	configs, _ := Grid([]Param{{Name: "threshold", Values: []float64{-2.0, 0.75, 3.0}}})
+	evaluate := func(trial int, config Config) *CrossValidation {
+		c := &shiftedClassifier{config["threshold"]}
+		return CrossValidate(func(train []Example) Learner {
+			return &constantLearner{c}
+		}, dataset, Folds{{0, 2}, {1, 3}})
+	}
+	for _, parallelism := range []int{1, 3} {
+		s, err := Search(configs, "accuracy", parallelism, evaluate)
+		if err != nil {
+			t.Fatalf("should have searched: %v", err)
+		}
+		if s.Best != 1 || s.BestConfig()["threshold"] != 0.75 {
+			t.Errorf("expected the threshold of 0.75 to be best but was trial %d", s.Best)
+		}
+		var buf bytes.Buffer
+		if err := s.WriteCSV(&buf); err != nil {
+			t.Fatalf("should have written the trials: %v", err)
+		}
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 4 || !strings.HasPrefix(lines[0], "trial,threshold,accuracy,accuracy_std,") || !strings.HasPrefix(lines[2], "1,0.75,1,0,") {
+			t.Errorf("expected a header and a row per trial but was\n%s", buf.String())
+		}
+	}
+
+	if _, err := Search(configs, "auc", 1, func(trial int, config Config) *CrossValidation {
+		t.Fatalf("expected no trials to run for an unknown metric")
+		return nil
+	}); err == nil {
+		t.Errorf("expected an unknown metric to be refused")
+	}
+}
+
+// shiftedClassifier predicts the score of a scoredDatum less a
+// threshold.
+type shiftedClassifier struct {
+	threshold float64
+}
+
+func (c *shiftedClassifier) Predict(e Example) float64 {
+	return e.(*scoredDatum).score - c.threshold
+}
+
+type constantLearner struct {
+	c Classifier
+}
+
+func (l *constantLearner) NewClassifier(es []Example) Classifier {
+	return l.c
+}
@@ -0,0 +1,187 @@
+package ml
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+)
+
+// SparseVector is a vector which is mostly zero, such as the counts
+// of the words in a document. Indices are in increasing order and
+// Values holds the value at each index.
+type SparseVector struct {
+	Indices []int
+	Values  []float64
+}
+
+// A SparseExample can be represented as a sparse vector.
+type SparseExample interface {
+	Example
+	Vector() SparseVector
+}
+
+// vectorOf returns the sparse vector of e, which must be a
+// SparseExample, possibly relabeled.
+func vectorOf(e Example) SparseVector {
+	return Unwrap(e).(SparseExample).Vector()
+}
+
+// NewSparseVector returns the vector with each value at its index.
+// Zero values are left out.
+func NewSparseVector(values map[int]float64) SparseVector {
+	var indices []int
+	for i, value := range values {
+		if value != 0.0 {
+			indices = append(indices, i)
+		}
+	}
+	sort.Ints(indices)
+	v := SparseVector{indices, make([]float64, len(indices))}
+	for k, i := range indices {
+		v.Values[k] = values[i]
+	}
+	return v
+}
+
+// Get returns the value at index i.
+func (v SparseVector) Get(i int) float64 {
+	k := sort.SearchInts(v.Indices, i)
+	if k < len(v.Indices) && v.Indices[k] == i {
+		return v.Values[k]
+	}
+	return 0.0
+}
+
+// Norm returns the Euclidean length of the vector.
+func (v SparseVector) Norm() float64 {
+	sum := 0.0
+	for _, value := range v.Values {
+		sum += value * value
+	}
+	return math.Sqrt(sum)
+}
+
+// FeatureHasher numbers named features, such as words, by hashing
+// their names, as in Weinberger et al., "Feature Hashing for Large
+// Scale Multitask Learning", 2009. Unlike a vocabulary it needs no
+// memory for the names and no pass over the examples, at the cost of
+// features sometimes sharing an index.
+type FeatureHasher struct {
+	// Dimension is how many indices there are.
+	Dimension int
+	// Signed negates the values of about half of the names, chosen by
+	// another bit of the hash, so that features which share an index
+	// cancel out on average rather than add up.
+	Signed bool
+}
+
+func NewFeatureHasher(dimension int) *FeatureHasher {
+	return &FeatureHasher{dimension, true}
+}
+
+// Index returns the index of the named feature and the sign of its
+// values.
+func (h *FeatureHasher) Index(name string) (int, float64) {
+	hash := fnv.New64a()
+	hash.Write([]byte(name))
+	// FNV mixes its high bits poorly for short names, so finish with
+	// the mixing step of MurmurHash3.
+	sum := hash.Sum64()
+	sum ^= sum >> 33
+	sum *= 0xff51afd7ed558ccd
+	sum ^= sum >> 33
+	sum *= 0xc4ceb9fe1a85ec53
+	sum ^= sum >> 33
+	sign := 1.0
+	if h.Signed && sum>>63 == 1 {
+		sign = -1.0
+	}
+	return int(sum % uint64(h.Dimension)), sign
+}
+
+// Vector returns the vector of the named features' values, such as
+// the counts of words. Values which share an index are summed.
+func (h *FeatureHasher) Vector(values map[string]float64) SparseVector {
+	hashed := make(map[int]float64)
+	for name, value := range values {
+		i, sign := h.Index(name)
+		hashed[i] += sign * value
+	}
+	return NewSparseVector(hashed)
+}
+
+// TFIDF weights the counts of the words in documents by how rare the
+// words are among the documents, term frequency–inverse document
+// frequency, and scales each document's vector to unit length.
+type TFIDF struct {
+	// IDF is the inverse document frequency of each index.
+	IDF []float64
+	// Sublinear dampens counts c to 1+log(c), so that a word which
+	// occurs many times in a document does not swamp the others.
+	Sublinear bool
+}
+
+// FitTFIDF measures the inverse document frequency of each index of
+// vectors of the given dimension, as log((1+n)/(1+df))+1, where n is
+// the number of vectors and df the number which have the index. The
+// smoothing gives indices which no vector has, like the words of new
+// documents, a finite weight.
+func FitTFIDF(vectors []SparseVector, dimension int) *TFIDF {
+	df := make([]int, dimension)
+	for _, v := range vectors {
+		for k, i := range v.Indices {
+			if i < dimension && v.Values[k] != 0.0 {
+				df[i]++
+			}
+		}
+	}
+	t := &TFIDF{make([]float64, dimension), false}
+	for i := range t.IDF {
+		t.IDF[i] = math.Log(float64(1+len(vectors))/float64(1+df[i])) + 1.0
+	}
+	return t
+}
+
+// Transform returns the TF-IDF vector of v, a vector of counts.
+// Indices beyond the dimension the TFIDF was fit to are left out.
+// Counts may be negative, as they are when hashed with signs; they
+// are weighted by their magnitude.
+func (t *TFIDF) Transform(v SparseVector) SparseVector {
+	var w SparseVector
+	for k, i := range v.Indices {
+		if i >= len(t.IDF) || v.Values[k] == 0.0 {
+			continue
+		}
+		tf := v.Values[k]
+		if t.Sublinear {
+			tf = math.Copysign(1.0+math.Log(math.Abs(tf)), tf)
+		}
+		w.Indices = append(w.Indices, i)
+		w.Values = append(w.Values, tf*t.IDF[i])
+	}
+	if norm := w.Norm(); norm > 0.0 {
+		for k := range w.Values {
+			w.Values[k] /= norm
+		}
+	}
+	return w
+}
+
+// VectorFeature is whether an example's vector has a value at Index,
+// so that decision trees can split on the indices of the vectors of
+// SparseExamples, such as hashed words.
+type VectorFeature struct {
+	Index int
+}
+
+func (f *VectorFeature) String() string {
+	return fmt.Sprintf("vector*%d", f.Index)
+}
+
+func (f *VectorFeature) Predict(e Example) float64 {
+	if vectorOf(e).Get(f.Index) != 0.0 {
+		return 1.0
+	}
+	return -1.0
+}
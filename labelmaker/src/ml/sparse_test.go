@@ -0,0 +1,101 @@
+package ml
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestNewSparseVector(t *testing.T) {
+	v := NewSparseVector(map[int]float64{7: 2.0, 1: 1.0, 3: 0.0})
+	if !reflect.DeepEqual(v, SparseVector{[]int{1, 7}, []float64{1.0, 2.0}}) {
+		t.Errorf("expected sorted indices without zeros but was %v", v)
+	}
+	if v.Get(7) != 2.0 || v.Get(3) != 0.0 || v.Get(8) != 0.0 {
+		t.Errorf("expected to get 2, 0 and 0 but was %f, %f and %f", v.Get(7), v.Get(3), v.Get(8))
+	}
+	if math.Abs(v.Norm()-math.Sqrt(5.0)) > 1e-9 {
+		t.Errorf("expected norm %f but was %f", math.Sqrt(5.0), v.Norm())
+	}
+}
+
+func TestFeatureHasher(t *testing.T) {
+	h := NewFeatureHasher(16)
+	counts := map[string]float64{"title*crash": 2.0, "content*crash": 1.0, "content*tab": 3.0}
+	v := h.Vector(counts)
+	if !reflect.DeepEqual(v, h.Vector(counts)) {
+		t.Errorf("expected hashing to be deterministic")
+	}
+	for _, i := range v.Indices {
+		if i < 0 || i >= 16 {
+			t.Errorf("expected indices from 0 to 15 but was %d", i)
+		}
+	}
+	i, sign := h.Index("title*crash")
+	if v.Get(i) == 0.0 {
+		t.Errorf("expected title*crash at index %d", i)
+	}
+
+	// With one index, signed values of both signs cancel out.
+	one := NewFeatureHasher(1)
+	negative, positive := 0, 0
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		if _, sign := one.Index(name); sign < 0.0 {
+			negative++
+		} else {
+			positive++
+		}
+	}
+	if negative == 0 || positive == 0 {
+		t.Errorf("expected names to hash to both signs but %d were negative and %d positive", negative, positive)
+	}
+	h.Signed = false
+	if _, sign = h.Index("title*crash"); sign != 1.0 {
+		t.Errorf("expected unsigned hashing to be positive but was %f", sign)
+	}
+}
+
+func TestTFIDF(t *testing.T) {
+	// Index 0 is in every document, 1 in one and 2 in none.
+	vectors := []SparseVector{
+		{[]int{0, 1}, []float64{1.0, 4.0}},
+		{[]int{0}, []float64{2.0}},
+	}
+	tfidf := FitTFIDF(vectors, 3)
+	expected := []float64{1.0, math.Log(3.0/2.0) + 1.0, math.Log(3.0) + 1.0}
+	for i := range expected {
+		if math.Abs(tfidf.IDF[i]-expected[i]) > 1e-9 {
+			t.Errorf("expected idf %f at index %d but was %f", expected[i], i, tfidf.IDF[i])
+		}
+	}
+
+	w := tfidf.Transform(SparseVector{[]int{0, 1, 5}, []float64{1.0, 4.0, 1.0}})
+	if !reflect.DeepEqual(w.Indices, []int{0, 1}) {
+		t.Fatalf("expected indices beyond the dimension to be left out but was %v", w.Indices)
+	}
+	if math.Abs(w.Norm()-1.0) > 1e-9 {
+		t.Errorf("expected a unit vector but the norm was %f", w.Norm())
+	}
+	if ratio := w.Values[1] / w.Values[0]; math.Abs(ratio-4.0*expected[1]) > 1e-9 {
+		t.Errorf("expected the rarer index to be weighted %f times as much but was %f", 4.0*expected[1], ratio)
+	}
+
+	tfidf.Sublinear = true
+	w = tfidf.Transform(SparseVector{[]int{0, 1}, []float64{1.0, -4.0}})
+	if ratio := w.Values[1] / w.Values[0]; math.Abs(ratio+(1.0+math.Log(4.0))*expected[1]) > 1e-9 {
+		t.Errorf("expected a dampened negative count but the ratio was %f", ratio)
+	}
+}
+
+func TestVectorFeature(t *testing.T) {
+	f := &VectorFeature{2}
+	if p := f.Predict(sparse([]int{1, 2}, []float64{1.0, -0.5}, true)); p != 1.0 {
+		t.Errorf("expected an example with index 2 to have the feature but predicted %f", p)
+	}
+	if p := f.Predict(sparse([]int{1, 3}, []float64{1.0, 1.0}, true)); p != -1.0 {
+		t.Errorf("expected an example without index 2 not to have the feature but predicted %f", p)
+	}
+	if f.String() != "vector*2" {
+		t.Errorf("expected vector*2 but was %s", f.String())
+	}
+}
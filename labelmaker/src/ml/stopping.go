@@ -0,0 +1,130 @@
+package ml
+
+import (
+	"fmt"
+	"time"
+)
+
+// StoppingPolicy decides when AdaBoost.Train stops. Each criterion
+// is disabled by its zero value; with none, training never stops.
+type StoppingPolicy struct {
+	// MaxRounds is the most rounds the model may have, counting any
+	// it had before training.
+	MaxRounds int
+	// Budget is how long training may take. Training stops after
+	// the first round which ends out of budget.
+	Budget time.Duration
+	// Validation are held out examples to choose the best round
+	// with.
+	Validation []Example
+	// Metric is the validation metric to choose the best round by,
+	// one of EvaluationMetrics. Lower is better for log_loss, and
+	// higher for the others.
+	Metric string
+	// Patience is how many rounds to wait for the validation metric
+	// to improve before stopping.
+	Patience int
+}
+
+type StopReason string
+
+const (
+	StoppedAtMaxRounds   StopReason = "max rounds"
+	StoppedOutOfBudget   StopReason = "out of time budget"
+	StoppedOutOfPatience StopReason = "out of patience"
+)
+
+// TrainingReport says how and why training stopped.
+type TrainingReport struct {
+	Reason StopReason
+	// Rounds is how many rounds the model had when training stopped.
+	Rounds int
+	// BestRound is how many rounds the model was truncated to, and
+	// BestMetric its validation metric. BestRound is Rounds without
+	// validation examples.
+	BestRound  int
+	BestMetric float64
+}
+
+func (r *TrainingReport) String() string {
+	return fmt.Sprintf("stopped after %d rounds (%s), keeping %d rounds with validation metric %f", r.Rounds, r.Reason, r.BestRound, r.BestMetric)
+}
+
+func metricImproves(metric string, value float64, best float64) bool {
+	if metric == "log_loss" {
+		return value < best
+	}
+	return value > best
+}
+
+// Train runs rounds of boosting, sampling nexamples examples each
+// round, until the policy says to stop. With validation examples, it
+// then truncates H and A to the round with the best validation
+// metric and recomputes D to match. progress, if not nil, is called
+// after each round with the number of rounds so far and the
+// validation evaluation, which is nil without validation examples.
+func (a *AdaBoost) Train(nexamples int, policy *StoppingPolicy, progress func(rounds int, validation *Evaluation)) *TrainingReport {
+	start := time.Now()
+	report := &TrainingReport{}
+	validate := len(policy.Validation) > 0
+
+	// Track the validation scores as rounds are added rather than
+	// predicting with the whole model every round.
+	scored := make([]scoredLabel, len(policy.Validation))
+	for i, e := range policy.Validation {
+		scored[i] = scoredLabel{a.Predict(e), e.Label()}
+	}
+	evaluate := func() *Evaluation {
+		// evaluateScores sorts its argument.
+		return evaluateScores(append([]scoredLabel(nil), scored...))
+	}
+	report.BestRound = len(a.H)
+	if validate && len(a.H) > 0 {
+		report.BestMetric = evaluate().Metric(policy.Metric)
+	}
+	sinceBest := 0
+
+	for {
+		if policy.MaxRounds > 0 && len(a.H) >= policy.MaxRounds {
+			report.Reason = StoppedAtMaxRounds
+			break
+		}
+
+		a.Round(nexamples)
+		t := len(a.H) - 1
+		var ev *Evaluation
+		if validate {
+			for i, e := range policy.Validation {
+				scored[i].score += a.A[t] * a.H[t].Predict(e)
+			}
+			ev = evaluate()
+			if value := ev.Metric(policy.Metric); report.BestRound == 0 || metricImproves(policy.Metric, value, report.BestMetric) {
+				report.BestRound, report.BestMetric = len(a.H), value
+				sinceBest = 0
+			} else {
+				sinceBest++
+			}
+		} else {
+			report.BestRound = len(a.H)
+		}
+		if progress != nil {
+			progress(len(a.H), ev)
+		}
+		if validate && policy.Patience > 0 && sinceBest >= policy.Patience {
+			report.Reason = StoppedOutOfPatience
+			break
+		}
+		if policy.Budget > 0 && time.Since(start) >= policy.Budget {
+			report.Reason = StoppedOutOfBudget
+			break
+		}
+	}
+
+	report.Rounds = len(a.H)
+	if report.BestRound < len(a.H) {
+		a.H = a.H[:report.BestRound]
+		a.A = a.A[:report.BestRound]
+		a.recomputeDistribution()
+	}
+	return report
+}
@@ -0,0 +1,78 @@
+package ml
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func noisyBitsDataset(r *rand.Rand, n int, nfeatures int) ([]Feature, []Example) {
+	var features []Feature
+	for i := 0; i < nfeatures; i++ {
+		features = append(features, bitFeature(i))
+	}
+	var dataset []Example
+	for i := 0; i < n; i++ {
+		d := &bitsDatum{make([]bool, nfeatures), false}
+		for j := range d.bits {
+			d.bits[j] = r.Intn(2) == 0
+		}
+		d.class = Label(d.bits[0] != (r.Intn(5) == 0))
+		dataset = append(dataset, d)
+	}
+	return features, dataset
+}
+
+func TestTrainStopsAtMaxRounds(t *testing.T) {
+	features, dataset := noisyBitsDataset(rand.New(rand.NewSource(1)), 100, 4)
+	booster := NewAdaBoost(dataset, NewDecisionTreeBuilder(features, 2), rand.New(rand.NewSource(42)))
+	calls := 0
+	report := booster.Train(50, &StoppingPolicy{MaxRounds: 4}, func(rounds int, ev *Evaluation) {
+		calls++
+		if ev != nil {
+			t.Errorf("expected no validation evaluation")
+		}
+	})
+	if report.Reason != StoppedAtMaxRounds || report.Rounds != 4 || report.BestRound != 4 || len(booster.H) != 4 {
+		t.Errorf("expected to stop after 4 rounds but was %v with %d rounds", report, len(booster.H))
+	}
+	if calls != 4 {
+		t.Errorf("expected progress to be reported 4 times but was %d", calls)
+	}
+}
+
+func TestTrainKeepsBestRound(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	features, dataset := noisyBitsDataset(r, 200, 8)
+	_, validation := noisyBitsDataset(r, 200, 8)
+	booster := NewAdaBoost(dataset, NewDecisionTreeBuilder(features, 3), rand.New(rand.NewSource(42)))
+	var metrics []float64
+	report := booster.Train(100, &StoppingPolicy{MaxRounds: 100, Validation: validation, Metric: "log_loss", Patience: 5}, func(rounds int, ev *Evaluation) {
+		metrics = append(metrics, ev.LogLoss)
+	})
+	if report.Reason != StoppedOutOfPatience {
+		t.Errorf("expected to run out of patience but was %v", report)
+	}
+	if report.Rounds-report.BestRound != 5 || len(booster.H) != report.BestRound || len(booster.A) != report.BestRound {
+		t.Errorf("expected to keep the round 5 before the last but was %v with %d rounds", report, len(booster.H))
+	}
+	for i, m := range metrics {
+		if m < report.BestMetric {
+			t.Errorf("expected the best log loss to be %f but round %d had %f", report.BestMetric, i+1, m)
+		}
+	}
+	if ev := Evaluate(booster, validation); ev.LogLoss != report.BestMetric {
+		t.Errorf("expected the truncated model to have log loss %f but was %f", report.BestMetric, ev.LogLoss)
+	}
+}
+
+func TestTrainStopsOutOfBudget(t *testing.T) {
+	features, dataset := noisyBitsDataset(rand.New(rand.NewSource(3)), 100, 4)
+	booster := NewAdaBoost(dataset, NewDecisionTreeBuilder(features, 2), rand.New(rand.NewSource(42)))
+	report := booster.Train(50, &StoppingPolicy{Budget: time.Nanosecond}, func(rounds int, ev *Evaluation) {
+		time.Sleep(time.Millisecond)
+	})
+	if report.Reason != StoppedOutOfBudget || report.Rounds != 1 {
+		t.Errorf("expected to run out of time after 1 round but was %v", report)
+	}
+}
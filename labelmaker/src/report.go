@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"ml"
+	"os"
+)
+
+// reportModel lists the features the model loaded from -load relies
+// on most: by the information gain of its trees on the dev issues,
+// and by how much shuffling each one hurts it on the validation
+// issues. It also writes the trees to the -tree-text and -dot files.
+func reportModel(r *rand.Rand, dev []ml.Example, validation []ml.Example) {
+	if *loadPath == "" {
+		log.Fatal("Report mode needs a model; use -load")
+	}
+	if !ml.IsEvaluationMetric(*reportMetric) {
+		log.Fatalf("Unknown metric \"%s\"; use -report-metric with one of %v", *reportMetric, ml.EvaluationMetrics)
+	}
+	booster, err := loadModel(*loadPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d rounds from %s\n", len(booster.H), *loadPath)
+
+	importances := ml.FeatureImportances(booster, dev, validation, *reportMetric, r)
+	fmt.Printf("%d features, top %d by gain; permutation is the loss of validation %s\n", len(importances), *top, *reportMetric)
+	fmt.Printf("%4s  %10s  %11s  %6s  %7s  %s\n", "rank", "gain", "permutation", "splits", "support", "feature")
+	for i, fi := range importances {
+		if i == *top {
+			break
+		}
+		fmt.Printf("%4d  %10f  %11f  %6d  %7d  %q\n", i+1, fi.Gain, fi.Permutation, fi.Splits, fi.Support, fi.Feature)
+	}
+
+	if *treeTextPath != "" {
+		writeFile(*treeTextPath, func(w io.Writer) error {
+			return ml.WriteEnsembleText(w, booster, dev)
+		})
+	}
+	if *dotPath != "" {
+		writeFile(*dotPath, func(w io.Writer) error {
+			return ml.WriteEnsembleDOT(w, booster, dev)
+		})
+	}
+}
+
+// writeFile creates path and writes to it with write.
+func writeFile(path string, write func(io.Writer) error) {
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := write(f); err != nil {
+		log.Fatalf("Writing %s: %v", path, err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"ml"
+)
+
+// searchParams returns the knobs of a learner to search, with values
+// for grid search and ranges for random search.
+func searchParams(learner string) []ml.Param {
+	minSupport := ml.Param{Name: "min-support", Values: []float64{0.0005, 0.001, 0.002}, Min: 0.0002, Max: 0.005, Log: true}
+	switch learner {
+	case "adaboost":
+		return []ml.Param{
+			{Name: "depth", Values: []float64{2, 3, 4}, Min: 2, Max: 6, Integer: true},
+			{Name: "sample-size", Values: []float64{500, 1000, 2000}, Min: 250, Max: 4000, Log: true, Integer: true},
+			{Name: "positive-prior", Values: []float64{0.1, 0.25, 0.5}, Min: 0.05, Max: 0.5},
+			minSupport,
+		}
+	case "forest":
+		return []ml.Param{
+			{Name: "depth", Values: []float64{5, 10, 15}, Min: 3, Max: 20, Integer: true},
+			{Name: "trees", Values: []float64{50, 100}, Min: 25, Max: 200, Log: true, Integer: true},
+			minSupport,
+		}
+	case "gbdt":
+		return []ml.Param{
+			{Name: "depth", Values: []float64{2, 3, 4}, Min: 2, Max: 6, Integer: true},
+			{Name: "learning-rate", Values: []float64{0.05, 0.1, 0.3}, Min: 0.01, Max: 0.5, Log: true},
+			{Name: "subsample", Values: []float64{0.5, 1.0}, Min: 0.3, Max: 1.0},
+			minSupport,
+		}
+	case "logistic":
+		return []ml.Param{
+			{Name: "l1", Values: []float64{0.0, 1e-6, 1e-4}, Min: 1e-8, Max: 1e-3, Log: true},
+			{Name: "l2", Values: []float64{1e-6, 1e-4, 1e-2}, Min: 1e-8, Max: 1e-1, Log: true},
+		}
+	case "multinomial-nb", "bernoulli-nb":
+		return []ml.Param{
+			{Name: "alpha", Values: []float64{0.1, 0.5, 1.0, 2.0}, Min: 0.01, Max: 5.0, Log: true},
+		}
+	default:
+		log.Fatalf("Unknown learner \"%s\"", learner)
+		return nil
+	}
+}
+
+// copyIssueExamples returns copies of the examples, so that
+// vectorizing them does not change the originals.
+func copyIssueExamples(es []ml.Example) []ml.Example {
+	copies := make([]ml.Example, len(es))
+	for i, e := range es {
+		c := *e.(*IssueExample)
+		copies[i] = &c
+	}
+	return copies
+}
+
+// searchBlink searches the knobs of the -learner for the config with
+// the best cross-validated Cr-Blink model, running -parallelism
+// trials at once. Every trial uses the same folds, and its own random
+// source, so the results do not depend on the order the trials run
+// in.
+func searchBlink(r *rand.Rand, es []ml.Example) {
+	if !ml.IsEvaluationMetric(*searchMetric) {
+		log.Fatalf("Unknown metric \"%s\"; use -search-metric with one of %v", *searchMetric, ml.EvaluationMetrics)
+	}
+	params := searchParams(*learnerName)
+	var configs []ml.Config
+	var err error
+	switch *searchMethod {
+	case "grid":
+		configs, err = ml.Grid(params)
+	case "random":
+		if *searchTrials < 1 {
+			log.Fatalf("Can not search %d random configs; use -search-trials 1 or more", *searchTrials)
+		}
+		configs, err = ml.RandomConfigs(params, *searchTrials, r)
+	default:
+		log.Fatalf("Unknown search method \"%s\"", *searchMethod)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(configs) == 0 {
+		log.Fatal("No configs to search")
+	}
+	folds := makeFolds(r, es)
+	fmt.Printf("searching %d configs of %s with %d folds\n", len(configs), *learnerName, len(folds))
+
+	result, err := ml.Search(configs, *searchMetric, *parallelism, func(trial int, config ml.Config) *ml.CrossValidation {
+		c := flagLearnerConfig()
+		c.parallelism = 1
+		for name, value := range config {
+			if err := c.set(name, value); err != nil {
+				log.Fatal(err)
+			}
+		}
+		trialExamples := copyIssueExamples(es)
+		cv := ml.CrossValidate(newLearnerFactory(rand.New(rand.NewSource(int64(trial))), trialExamples, c), trialExamples, folds)
+		fmt.Printf("trial %d %v: %v\n", trial, config, cv)
+		return cv
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	best := result.Trials[result.Best]
+	fmt.Printf("best trial %d %v: %s=%f\n", result.Best, best.Config, *searchMetric, best.Validation.Metrics[*searchMetric].Mean)
+	writeFile(*searchResultsPath, result.WriteCSV)
+	writeFile(*searchBestPath, result.WriteBestJSON)
+}
@@ -0,0 +1,123 @@
+// Package terms turns the text of issues into terms: normalized
+// words, identifiers and n-grams, which labelmaker makes title and
+// content features of.
+//
+// Terms never contain *, which separates the kind of a feature from
+// its term in feature names, so every term makes a valid feature.
+package terms
+
+import (
+	"strings"
+)
+
+// Featurizer turns text into terms.
+type Featurizer struct {
+	// Lowercase folds the case of words and identifiers.
+	Lowercase bool
+	// StopWords are left out of the terms. Identifiers are never
+	// stop words, but their parts may be.
+	StopWords map[string]bool
+	// Stem reduces words, and the parts of identifiers, to their
+	// Porter stems. Whole identifiers are not stemmed.
+	Stem bool
+	// WordNGrams is how many consecutive words, after stop words are
+	// left out, are the most that make a term, joined by spaces; 0 and
+	// 1 make terms of single words only.
+	WordNGrams int
+	// CharNGrams, if not 0, is the length of the runs of characters
+	// in each word which are also made terms. Words are marked with <
+	// at the start and > at the end, so that their prefixes and
+	// suffixes are distinct, and each n-gram is prefixed with # so
+	// that it is distinct from words.
+	CharNGrams int
+}
+
+// NewFeaturizer returns a featurizer which lowercases words, leaves
+// out English stop words and stems what is left.
+func NewFeaturizer() *Featurizer {
+	return &Featurizer{true, EnglishStopWords, true, 1, 0}
+}
+
+// word returns the term for a word, or false if it is a stop word.
+func (f *Featurizer) word(w string) (string, bool) {
+	w = normalize(w, f.Lowercase)
+	if w == "" || f.StopWords[w] {
+		return "", false
+	}
+	if f.Stem {
+		w = Stem(w)
+	}
+	return w, true
+}
+
+// Terms returns the terms of text, in order and with repeats, so that
+// they can be counted. Identifiers make a term of their own and one
+// for each of their parts; word and character n-grams follow.
+func (f *Featurizer) Terms(s string) []string {
+	var terms []string
+	// words are the terms n-grams are made from, and surface the same
+	// words before they were stemmed.
+	var words, surface []string
+	for _, token := range Tokenize(s) {
+		if token.Identifier {
+			identifier := normalize(token.Text, f.Lowercase)
+			terms = append(terms, identifier)
+			words = append(words, identifier)
+			surface = append(surface, identifier)
+			for _, part := range token.Parts() {
+				if w, ok := f.word(part); ok {
+					terms = append(terms, w)
+				}
+			}
+			continue
+		}
+		if w, ok := f.word(token.Text); ok {
+			terms = append(terms, w)
+			words = append(words, w)
+			surface = append(surface, normalize(token.Text, f.Lowercase))
+		}
+	}
+	terms = append(terms, WordNGrams(words, f.WordNGrams)...)
+	if f.CharNGrams > 0 {
+		for _, w := range surface {
+			terms = append(terms, CharNGrams(w, f.CharNGrams)...)
+		}
+	}
+	return terms
+}
+
+// Set returns the distinct terms of text.
+func (f *Featurizer) Set(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, term := range f.Terms(s) {
+		set[term] = true
+	}
+	return set
+}
+
+// WordNGrams returns the runs of from 2 to n consecutive words, each
+// joined by spaces, shortest first.
+func WordNGrams(words []string, n int) []string {
+	var ngrams []string
+	for k := 2; k <= n; k++ {
+		for i := 0; i+k <= len(words); i++ {
+			ngrams = append(ngrams, strings.Join(words[i:i+k], " "))
+		}
+	}
+	return ngrams
+}
+
+// CharNGrams returns the runs of n characters in word, marked as
+// Featurizer.CharNGrams describes. Words too short to have any are
+// made a single n-gram.
+func CharNGrams(word string, n int) []string {
+	rs := []rune("<" + word + ">")
+	if len(rs) <= n {
+		return []string{"#" + string(rs)}
+	}
+	ngrams := make([]string, 0, len(rs)-n+1)
+	for i := 0; i+n <= len(rs); i++ {
+		ngrams = append(ngrams, "#"+string(rs[i:i+n]))
+	}
+	return ngrams
+}
@@ -0,0 +1,209 @@
+package terms
+
+// Stem returns the Porter stem of a lowercase English word, as
+// described in Porter, "An algorithm for suffix stripping", 1980, with
+// the revisions of the reference implementation. Words of two letters
+// or fewer, and words with anything but the letters a to z, are
+// returned as they are.
+func Stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || 'z' < word[i] {
+			return word
+		}
+	}
+	s := &stemmer{[]byte(word)}
+	s.step1ab()
+	s.step1c()
+	s.replaceFirst(step2, 0)
+	s.replaceFirst(step3, 0)
+	s.replaceFirst(step4, 1)
+	s.step5()
+	return string(s.b)
+}
+
+// stemmer holds a word as it is stemmed.
+type stemmer struct {
+	b []byte
+}
+
+// consonant returns whether b[i] is a consonant: a letter other than
+// a, e, i, o or u, and other than a y which follows a consonant.
+func (s *stemmer) consonant(i int) bool {
+	switch s.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !s.consonant(i-1)
+	}
+	return true
+}
+
+// measure returns m, the number of vowel-consonant sequences in
+// b[:j], which has the form [C](VC){m}[V].
+func (s *stemmer) measure(j int) int {
+	i := 0
+	for i < j && s.consonant(i) {
+		i++
+	}
+	m := 0
+	for i < j {
+		for i < j && !s.consonant(i) {
+			i++
+		}
+		if i == j {
+			break
+		}
+		for i < j && s.consonant(i) {
+			i++
+		}
+		m++
+	}
+	return m
+}
+
+// hasVowel returns whether b[:j] contains a vowel.
+func (s *stemmer) hasVowel(j int) bool {
+	for i := 0; i < j; i++ {
+		if !s.consonant(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// doubleConsonant returns whether b[:j] ends in a double consonant.
+func (s *stemmer) doubleConsonant(j int) bool {
+	return j >= 2 && s.b[j-1] == s.b[j-2] && s.consonant(j-1)
+}
+
+// cvc returns whether b[:j] ends consonant-vowel-consonant, where the
+// last consonant is not w, x or y, as in hop but not in snow.
+func (s *stemmer) cvc(j int) bool {
+	if j < 3 || !s.consonant(j-3) || s.consonant(j-2) || !s.consonant(j-1) {
+		return false
+	}
+	switch s.b[j-1] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+func (s *stemmer) hasSuffix(suffix string) bool {
+	return len(s.b) >= len(suffix) && string(s.b[len(s.b)-len(suffix):]) == suffix
+}
+
+// replace replaces the suffix, which the word must have.
+func (s *stemmer) replace(suffix string, replacement string) {
+	s.b = append(s.b[:len(s.b)-len(suffix)], replacement...)
+}
+
+func (s *stemmer) step1ab() {
+	switch {
+	case s.hasSuffix("sses"):
+		s.replace("sses", "ss")
+	case s.hasSuffix("ies"):
+		s.replace("ies", "i")
+	case s.hasSuffix("ss"):
+	case s.hasSuffix("s"):
+		s.replace("s", "")
+	}
+
+	if s.hasSuffix("eed") {
+		if s.measure(len(s.b)-3) > 0 {
+			s.replace("eed", "ee")
+		}
+		return
+	}
+	switch {
+	case s.hasSuffix("ed") && s.hasVowel(len(s.b)-2):
+		s.replace("ed", "")
+	case s.hasSuffix("ing") && s.hasVowel(len(s.b)-3):
+		s.replace("ing", "")
+	default:
+		return
+	}
+	switch n := len(s.b); {
+	case s.hasSuffix("at"):
+		s.replace("at", "ate")
+	case s.hasSuffix("bl"):
+		s.replace("bl", "ble")
+	case s.hasSuffix("iz"):
+		s.replace("iz", "ize")
+	case s.doubleConsonant(n):
+		if last := s.b[n-1]; last != 'l' && last != 's' && last != 'z' {
+			s.b = s.b[:n-1]
+		}
+	case s.measure(n) == 1 && s.cvc(n):
+		s.b = append(s.b, 'e')
+	}
+}
+
+func (s *stemmer) step1c() {
+	if s.hasSuffix("y") && s.hasVowel(len(s.b)-1) {
+		s.b[len(s.b)-1] = 'i'
+	}
+}
+
+// suffixRule replaces a suffix.
+type suffixRule struct {
+	suffix      string
+	replacement string
+}
+
+var step2 = []suffixRule{
+	{"ational", "ate"}, {"tional", "tion"},
+	{"enci", "ence"}, {"anci", "ance"},
+	{"izer", "ize"},
+	{"bli", "ble"}, {"alli", "al"}, {"entli", "ent"}, {"eli", "e"}, {"ousli", "ous"},
+	{"ization", "ize"}, {"ation", "ate"}, {"ator", "ate"},
+	{"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"}, {"ousness", "ous"},
+	{"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"},
+	{"logi", "log"},
+}
+
+var step3 = []suffixRule{
+	{"icate", "ic"}, {"ative", ""}, {"alize", "al"}, {"iciti", "ic"},
+	{"ical", "ic"}, {"ful", ""}, {"ness", ""},
+}
+
+var step4 = []suffixRule{
+	{"al", ""}, {"ance", ""}, {"ence", ""}, {"er", ""}, {"ic", ""},
+	{"able", ""}, {"ible", ""}, {"ant", ""}, {"ement", ""}, {"ment", ""},
+	{"ent", ""}, {"ion", ""}, {"ou", ""}, {"ism", ""}, {"ate", ""},
+	{"iti", ""}, {"ous", ""}, {"ive", ""}, {"ize", ""},
+}
+
+// replaceFirst applies the first of the rules whose suffix the word
+// has, if what precedes the suffix has a measure greater than
+// minMeasure. Later rules are not tried even if it does not.
+func (s *stemmer) replaceFirst(rules []suffixRule, minMeasure int) {
+	for _, rule := range rules {
+		if !s.hasSuffix(rule.suffix) {
+			continue
+		}
+		j := len(s.b) - len(rule.suffix)
+		// -ion is only a suffix after s or t.
+		if rule.suffix == "ion" && (j == 0 || (s.b[j-1] != 's' && s.b[j-1] != 't')) {
+			continue
+		}
+		if s.measure(j) > minMeasure {
+			s.replace(rule.suffix, rule.replacement)
+		}
+		return
+	}
+}
+
+func (s *stemmer) step5() {
+	if n := len(s.b); s.hasSuffix("e") {
+		if m := s.measure(n - 1); m > 1 || (m == 1 && !s.cvc(n-1)) {
+			s.b = s.b[:n-1]
+		}
+	}
+	if n := len(s.b); s.hasSuffix("ll") && s.measure(n) > 1 {
+		s.b = s.b[:n-1]
+	}
+}
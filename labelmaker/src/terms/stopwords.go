@@ -0,0 +1,33 @@
+package terms
+
+// EnglishStopWords are common English words which say little about
+// what a document is about.
+var EnglishStopWords = stopWords(
+	"a", "about", "above", "after", "again", "against", "all", "am",
+	"an", "and", "any", "are", "aren't", "as", "at", "be", "because",
+	"been", "before", "being", "below", "between", "both", "but", "by",
+	"can", "can't", "cannot", "could", "couldn't", "did", "didn't",
+	"do", "does", "doesn't", "doing", "don't", "down", "during", "each",
+	"few", "for", "from", "further", "had", "hadn't", "has", "hasn't",
+	"have", "haven't", "having", "he", "her", "here", "hers", "herself",
+	"him", "himself", "his", "how", "i", "i'd", "i'll", "i'm", "i've",
+	"if", "in", "into", "is", "isn't", "it", "its", "itself", "let's",
+	"me", "more", "most", "my", "myself", "no", "nor", "not", "of",
+	"off", "on", "once", "only", "or", "other", "ought", "our", "ours",
+	"ourselves", "out", "over", "own", "same", "she", "should",
+	"shouldn't", "so", "some", "such", "than", "that", "the", "their",
+	"theirs", "them", "themselves", "then", "there", "these", "they",
+	"this", "those", "through", "to", "too", "under", "until", "up",
+	"very", "was", "wasn't", "we", "were", "weren't", "what", "when",
+	"where", "which", "while", "who", "whom", "why", "with", "won't",
+	"would", "wouldn't", "you", "your", "yours", "yourself",
+	"yourselves",
+)
+
+func stopWords(words ...string) map[string]bool {
+	m := make(map[string]bool)
+	for _, word := range words {
+		m[word] = true
+	}
+	return m
+}
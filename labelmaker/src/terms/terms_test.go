@@ -0,0 +1,61 @@
+package terms
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tokens := Tokenize("Crash, in blink::LayoutObject\nsee third_party/WebKit/Node.cpp. Don't\tstop™ /usr/lib 日本語")
+	expected := []Token{
+		{"Crash", false},
+		{"in", false},
+		{"blink::LayoutObject", true},
+		{"see", false},
+		{"third_party/WebKit/Node.cpp", true},
+		{"Don't", false},
+		{"stop", false},
+		{"/usr/lib", true},
+		{"日本語", false},
+	}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("expected tokens %v but was %v", expected, tokens)
+	}
+	if tokens := Tokenize("HTMLElement"); len(tokens) != 1 || !tokens[0].Identifier {
+		t.Errorf("expected a camel case word to be an identifier but was %v", tokens)
+	}
+}
+
+func TestParts(t *testing.T) {
+	for text, expected := range map[string][]string{
+		"blink::LayoutObject":         {"blink", "Layout", "Object"},
+		"HTMLElement":                 {"HTML", "Element"},
+		"third_party/WebKit/Node.cpp": {"third", "party", "Web", "Kit", "Node", "cpp"},
+		"Utf8Decoder":                 {"Utf8", "Decoder"},
+		"/usr/lib":                    {"usr", "lib"},
+	} {
+		if parts := (Token{text, true}).Parts(); !reflect.DeepEqual(parts, expected) {
+			t.Errorf("expected the parts of %s to be %v but was %v", text, expected, parts)
+		}
+	}
+}
+
+func TestTerms(t *testing.T) {
+	f := NewFeaturizer()
+	terms := f.Terms("The renderer crashes, Crash in blink::LayoutObject's layout")
+	expected := []string{"render", "crash", "crash", "blink::layoutobject", "blink", "layout", "object", "layout"}
+	if !reflect.DeepEqual(terms, expected) {
+		t.Errorf("expected terms %v but was %v", expected, terms)
+	}
+
+	f.WordNGrams = 2
+	f.CharNGrams = 3
+	terms = f.Terms("tab crashes")
+	expected = []string{"tab", "crash", "tab crash", "#<ta", "#tab", "#ab>", "#<cr", "#cra", "#ras", "#ash", "#she", "#hes", "#es>"}
+	if !reflect.DeepEqual(terms, expected) {
+		t.Errorf("expected terms %v but was %v", expected, terms)
+	}
+	if set := f.Set("a"); len(set) != 0 {
+		t.Errorf("expected a stop word to have no terms but was %v", set)
+	}
+}
@@ -0,0 +1,149 @@
+package terms
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Token is a word of text, or an identifier such as
+// blink::LayoutObject, LayoutObject or layout_object, or a path such
+// as third_party/WebKit/Source/core/dom/Node.cpp.
+type Token struct {
+	Text string
+	// Identifier is set for tokens whose words are joined by
+	// connectors, underscores or changes of case; see Parts.
+	Identifier bool
+}
+
+// connectors join words into identifiers and paths when there is a
+// word on either side.
+var connectors = []string{"::", "->", "/", `\`, ".", "-"}
+
+// apostrophes join words like don't without making them identifiers.
+var apostrophes = []string{"'", "’"}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || r == '_'
+}
+
+// joinedBy returns the length in runes of joiner if it is at rs[i]
+// and followed by a word, or 0 if it is not.
+func joinedBy(rs []rune, i int, joiner string) int {
+	n := len([]rune(joiner))
+	if i+n < len(rs) && string(rs[i:i+n]) == joiner && isWordRune(rs[i+n]) {
+		return n
+	}
+	return 0
+}
+
+// joinerAt returns the length of the connector or apostrophe at rs[i]
+// which is followed by a word, or 0 if there is none, and whether it
+// is an apostrophe.
+func joinerAt(rs []rune, i int) (int, bool) {
+	for _, connector := range connectors {
+		if n := joinedBy(rs, i, connector); n > 0 {
+			return n, false
+		}
+	}
+	for _, apostrophe := range apostrophes {
+		if n := joinedBy(rs, i, apostrophe); n > 0 {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// caseChangeAt returns whether a new word starts at rs[i] in camel
+// case: a capital after a lowercase letter or digit, as in
+// LayoutObject, or the last of several capitals before a lowercase
+// letter, as in HTMLElement.
+func caseChangeAt(rs []rune, i int) bool {
+	if i == 0 || !unicode.IsUpper(rs[i]) {
+		return false
+	}
+	return unicode.IsLower(rs[i-1]) || unicode.IsDigit(rs[i-1]) ||
+		(unicode.IsUpper(rs[i-1]) && i+1 < len(rs) && unicode.IsLower(rs[i+1]))
+}
+
+// Tokenize splits text into tokens. Words are runs of Unicode letters,
+// digits and marks; punctuation and white space separate them, except
+// for connectors between words, which join them into identifiers. A
+// path may start with a slash.
+func Tokenize(s string) []Token {
+	var tokens []Token
+	rs := []rune(s)
+	for i := 0; i < len(rs); {
+		start := i
+		identifier := false
+		if rs[i] == '/' && i+1 < len(rs) && isWordRune(rs[i+1]) {
+			identifier = true
+			i++
+		} else if !isWordRune(rs[i]) {
+			i++
+			continue
+		}
+		for {
+			for i < len(rs) && isWordRune(rs[i]) {
+				i++
+			}
+			n, apostrophe := joinerAt(rs, i)
+			if n == 0 {
+				break
+			}
+			identifier = identifier || !apostrophe
+			i += n
+		}
+		text := rs[start:i]
+		for j := range text {
+			identifier = identifier || text[j] == '_' || caseChangeAt(text, j)
+		}
+		tokens = append(tokens, Token{string(text), identifier})
+	}
+	return tokens
+}
+
+func isApostrophe(r rune) bool {
+	return r == '\'' || r == '’'
+}
+
+// Parts returns the words of an identifier, split at connectors,
+// underscores and changes of case: blink::LayoutObject has the parts
+// blink, Layout and Object. Apostrophes stay in their words.
+func (t Token) Parts() []string {
+	var parts []string
+	rs := []rune(t.Text)
+	start := -1
+	for i, r := range rs {
+		if r == '_' || (!isWordRune(r) && !isApostrophe(r)) {
+			if start >= 0 {
+				parts = append(parts, string(rs[start:i]))
+				start = -1
+			}
+			continue
+		}
+		if start >= 0 && caseChangeAt(rs, i) {
+			parts = append(parts, string(rs[start:i]))
+			start = i
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		parts = append(parts, string(rs[start:]))
+	}
+	return parts
+}
+
+// normalize lowercases a word, if lowercase is set, makes its
+// apostrophes plain and drops a possessive 's.
+func normalize(word string, lowercase bool) string {
+	if lowercase {
+		word = strings.ToLower(word)
+	}
+	word = strings.Replace(word, "’", "'", -1)
+	if strings.HasSuffix(word, "'s") || strings.HasSuffix(word, "'S") {
+		word = word[:len(word)-2]
+	}
+	return word
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"log"
+	"ml"
+	"sort"
+)
+
+// vocabulary numbers the title and content words which are the
+// indices of sparse vectors.
+type vocabulary struct {
+	// index maps the names of words, as title and content features
+	// name them, to indices.
+	index map[string]int
+	// features has the feature for each index.
+	features []ml.Feature
+}
+
+// issueWordCounts counts the title and content words of an issue,
+// split the same way as for the title and content features, by
+// feature name.
+func issueWordCounts(e *IssueExample) map[string]float64 {
+	counts := make(map[string]float64)
+	for _, word := range issueWords(e.Title) {
+		counts[(&titleFeature{word}).String()]++
+	}
+	for _, word := range issueWords(e.Content) {
+		counts[(&contentFeature{word}).String()]++
+	}
+	return counts
+}
+
+// buildVocabulary numbers the words in the examples in alphabetical
+// order, so that the numbering does not depend on map iteration.
+func buildVocabulary(examples []ml.Example) *vocabulary {
+	features := make(map[string]ml.Feature)
+	for _, e := range examples {
+		i := issueExampleOf(e)
+		for word := range i.titleWords {
+			f := &titleFeature{word}
+			features[f.String()] = f
+		}
+		for word := range i.contentWords {
+			f := &contentFeature{word}
+			features[f.String()] = f
+		}
+	}
+	var names []string
+	for name := range features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	v := &vocabulary{make(map[string]int), make([]ml.Feature, len(names))}
+	for i, name := range names {
+		v.index[name] = i
+		v.features[i] = features[name]
+	}
+	return v
+}
+
+// vectorize sets the vectors of the examples to their word counts.
+// Words which are not in the vocabulary are left out.
+func vectorize(v *vocabulary, examples []ml.Example) {
+	for _, e := range examples {
+		i := issueExampleOf(e)
+		var indices []int
+		counts := make(map[int]float64)
+		for word, n := range issueWordCounts(i) {
+			if index, ok := v.index[word]; ok {
+				indices = append(indices, index)
+				counts[index] = n
+			}
+		}
+		sort.Ints(indices)
+		i.vector = ml.SparseVector{Indices: indices, Values: make([]float64, len(indices))}
+		for j, index := range indices {
+			i.vector.Values[j] = counts[index]
+		}
+	}
+}
+
+// hasher numbers words by hashing their feature names, as -vectors
+// says; nil numbers them by a vocabulary of the training examples.
+var hasher *ml.FeatureHasher
+
+// newHasher returns the feature hasher the vector flags describe.
+func newHasher() *ml.FeatureHasher {
+	switch *vectors {
+	case "vocabulary":
+		return nil
+	case "hash":
+		if *hashBits < 1 || *hashBits > 62 {
+			log.Fatalf("Can not hash words into 2^%d features; use -hash-bits from 1 to 62", *hashBits)
+		}
+		h := ml.NewFeatureHasher(1 << uint(*hashBits))
+		h.Signed = *signedHash
+		return h
+	default:
+		log.Fatalf("Unknown vectors \"%s\"", *vectors)
+		return nil
+	}
+}
+
+// hashedVector returns the hashed word counts of an issue.
+func hashedVector(i *IssueExample) ml.SparseVector {
+	return hasher.Vector(issueWordCounts(i))
+}
+
+// vectorizeFold sets the vectors of es, all of the examples being
+// cross-validated, for learning from the train examples: by the
+// train examples' vocabulary unless words are hashed, then weighted
+// as -weighting says, by document frequencies in the train examples.
+// It returns the dimension of the vectors and the feature at each
+// index, or nil if words are hashed.
+func vectorizeFold(train []ml.Example, es []ml.Example) (int, []ml.Feature) {
+	var dimension int
+	var features []ml.Feature
+	if hasher == nil {
+		v := buildVocabulary(train)
+		vectorize(v, es)
+		dimension, features = len(v.features), v.features
+	} else {
+		// Earlier folds may have weighted the vectors.
+		for _, e := range es {
+			i := issueExampleOf(e)
+			i.vector = hashedVector(i)
+		}
+		dimension = hasher.Dimension
+	}
+
+	var tfidf *ml.TFIDF
+	switch *weighting {
+	case "count":
+		return dimension, features
+	case "tfidf", "sublinear-tfidf":
+		vectors := make([]ml.SparseVector, len(train))
+		for j, e := range train {
+			vectors[j] = issueExampleOf(e).vector
+		}
+		tfidf = ml.FitTFIDF(vectors, dimension)
+		tfidf.Sublinear = *weighting == "sublinear-tfidf"
+	default:
+		log.Fatalf("Unknown weighting \"%s\"", *weighting)
+	}
+	for _, e := range es {
+		i := issueExampleOf(e)
+		i.vector = tfidf.Transform(i.vector)
+	}
+	return dimension, features
+}
+
+// hashedFeaturePostings is wordFeaturePostings for hashed words:
+// its features are the indices of the examples' vectors, so there are
+// at most 2^-hash-bits of them however many words there are.
+func hashedFeaturePostings(examples []ml.Example, minExamples int) ([]ml.Feature, [][]int) {
+	postings := make(map[int][]int)
+	for j, e := range examples {
+		for _, index := range issueExampleOf(e).vector.Indices {
+			postings[index] = append(postings[index], j)
+		}
+	}
+	var indices []int
+	for index, posting := range postings {
+		if len(posting) >= minExamples {
+			indices = append(indices, index)
+		}
+	}
+	sort.Ints(indices)
+	features := make([]ml.Feature, len(indices))
+	featurePostings := make([][]int, len(indices))
+	for k, index := range indices {
+		features[k] = &ml.VectorFeature{Index: index}
+		featurePostings[k] = postings[index]
+	}
+	return features, featurePostings
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"issues"
+	"math"
+	"ml"
+	"terms"
+	"testing"
+)
+
+// withVectorFlags sets the featurizer, hasher and -weighting for a
+// test, and returns a function which restores them.
+func withVectorFlags(f *terms.Featurizer, h *ml.FeatureHasher, w string) func() {
+	oldFeaturizer, oldHasher, oldWeighting := featurizer, hasher, *weighting
+	featurizer, hasher, *weighting = f, h, w
+	return func() {
+		featurizer, hasher, *weighting = oldFeaturizer, oldHasher, oldWeighting
+	}
+}
+
+func issueWithContent(id int, content string) *IssueExample {
+	return NewIssueExample(&issues.Issue{Id: id, Content: content})
+}
+
+func TestVectorizeFoldFitsTFIDFOnTrain(t *testing.T) {
+	h := ml.NewFeatureHasher(1 << 10)
+	h.Signed = false
+	defer withVectorFlags(terms.NewFeaturizer(), h, "tfidf")()
+
+	train := []ml.Example{
+		issueWithContent(1, "tab crash"),
+		issueWithContent(2, "tab hang"),
+		issueWithContent(3, "tab crash render"),
+	}
+	// The held out issue's word zebra is in none of the train issues,
+	// and crash is in one of the held out issues as well.
+	heldOut := []ml.Example{
+		issueWithContent(4, "zebra crash"),
+		issueWithContent(5, "crash crash"),
+	}
+	es := append(append([]ml.Example(nil), train...), heldOut...)
+
+	raw := func(es []ml.Example) []ml.SparseVector {
+		vectors := make([]ml.SparseVector, len(es))
+		for j, e := range es {
+			vectors[j] = hashedVector(issueExampleOf(e))
+		}
+		return vectors
+	}
+	fitOnTrain := ml.FitTFIDF(raw(train), h.Dimension)
+	fitOnAll := ml.FitTFIDF(raw(es), h.Dimension)
+	expected := fitOnTrain.Transform(hashedVector(issueExampleOf(heldOut[0])))
+	if all := fitOnAll.Transform(hashedVector(issueExampleOf(heldOut[0]))); math.Abs(all.Values[0]-expected.Values[0]) < 1e-9 {
+		t.Fatalf("expected the held out issues to change the weights")
+	}
+
+	// Vectorizing twice must not weight the vectors twice.
+	for k := 0; k < 2; k++ {
+		dimension, features := vectorizeFold(train, es)
+		if dimension != h.Dimension || features != nil {
+			t.Fatalf("expected dimension %d and no features but was %d and %v", h.Dimension, dimension, features)
+		}
+		v := issueExampleOf(heldOut[0]).vector
+		if len(v.Indices) != len(expected.Indices) {
+			t.Fatalf("expected vector %v but was %v", expected, v)
+		}
+		for j := range v.Indices {
+			if v.Indices[j] != expected.Indices[j] || math.Abs(v.Values[j]-expected.Values[j]) > 1e-9 {
+				t.Fatalf("expected the weights fit on the train issues %v but was %v", expected, v)
+			}
+		}
+	}
+}
+
+func TestHashedFeaturePostings(t *testing.T) {
+	// With one index, every word hashes to it, with either sign.
+	h := ml.NewFeatureHasher(1)
+	defer withVectorFlags(terms.NewFeaturizer(), h, "count")()
+
+	// Find two words whose signs cancel out.
+	words := []string{"tab", "crash", "hang", "render", "zebra", "font", "scroll", "print"}
+	var cancelled *IssueExample
+	for _, w1 := range words {
+		for _, w2 := range words {
+			if e := issueWithContent(1, w1+" "+w2); w1 != w2 && len(e.vector.Indices) == 0 {
+				cancelled = e
+			}
+		}
+	}
+	if cancelled == nil {
+		t.Fatalf("expected two of %v to hash to opposite signs", words)
+	}
+	examples := []ml.Example{cancelled, issueWithContent(2, "tab"), issueWithContent(3, "")}
+
+	features, postings := hashedFeaturePostings(examples, 1)
+	if len(features) != 1 {
+		t.Fatalf("expected one feature but was %v", features)
+	}
+	for k, f := range features {
+		has := make(map[int]bool)
+		for _, j := range postings[k] {
+			has[j] = true
+		}
+		for j, e := range examples {
+			if (f.Predict(e) > 0.0) != has[j] {
+				t.Errorf("expected the postings of %v to agree with its prediction for example %d", f, j)
+			}
+		}
+	}
+	if f := features[0]; f.Predict(cancelled) > 0.0 {
+		t.Errorf("expected words which cancel out not to have %v", f)
+	}
+}